@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/aws/aws-xray-sdk-go/xraylog"
+	"github.com/danesparza/pollen/data"
+)
+
+// Exit codes for the command line client
+const (
+	exitOK       = 0 // Every report came back
+	exitFailure  = 1 // None of the services came through for at least one zipcode
+	exitUsage    = 2 // The command line (or the request) was bad
+	exitDegraded = 3 // Every report came back, but some services failed
+)
+
+// exitSeverity ranks the exit codes, so the worst one is returned
+var exitSeverity = map[int]int{
+	exitOK:       0,
+	exitDegraded: 1,
+	exitFailure:  2,
+	exitUsage:    3,
+}
+
+// Output formats for the command line client
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// cliCommands are the subcommands the command line client handles
+var cliCommands = map[string]bool{
+	"dry-run":   true,
+	"get":       true,
+	"providers": true,
+	"raw":       true,
+}
+
+// cli is the command line client
+type cli struct {
+	getReport reportFunc              // Gets the report for a message
+	active    []data.SelectedProvider // The active providers
+	stdout    io.Writer               // Where the output goes
+	stderr    io.Writer               // Where errors and usage go
+}
+
+// cliResult is the result for one zipcode, as written in the JSON formats
+type cliResult struct {
+	Zipcode   string                `json:"zipcode"`
+	Report    *data.PollenReport    `json:"report,omitempty"`
+	Error     string                `json:"error,omitempty"`
+	Providers []*data.ProviderError `json:"providers,omitempty"`
+}
+
+// providerInfo describes an active provider and its service, as written by the
+// providers command
+type providerInfo struct {
+	data.ProviderInfo
+	Service string              `json:"service"`
+	Scale   *data.IndexScale    `json:"scale,omitempty"`
+	Kinds   []data.ForecastKind `json:"kinds"`
+	History bool                `json:"history"`
+	Raw     bool                `json:"raw"`
+}
+
+// run runs the command (like 'get 30019') and returns the exit code
+func (c cli) run(args []string) int {
+	if len(args) == 0 || !cliCommands[args[0]] {
+		c.usage()
+		return exitUsage
+	}
+
+	//	Keep X-Ray from writing to our output
+	xray.SetLogger(xraylog.NewDefaultLogger(c.stderr, xraylog.LogLevelError))
+
+	switch args[0] {
+	case "get":
+		return c.get(args[1:])
+	case "providers":
+		return c.providers(args[1:])
+	case "dry-run":
+		return c.dryRun(args[1:])
+	}
+
+	return c.raw(args[1:])
+}
+
+// usage writes the command line usage
+func (c cli) usage() {
+	fmt.Fprintln(c.stderr, `Usage:
+  pollen get [-format table|json|ndjson|csv] [-strategy first|consensus|hedged] [-merge median|mean] [-kinds pollen,asthma,coldflu] [-services nasacort,pollencom] [-timeout 10s] ZIP [ZIP...]
+  pollen providers [-format table|json]
+  pollen raw [-timeout 10s] PROVIDER ZIP
+  pollen dry-run [-config providers.json] [-provider NAME] [-zip ZIP] PAYLOAD
+
+Exit codes:
+  0  every report came back
+  1  none of the services came through for at least one zipcode
+  2  bad command line or request
+  3  every report came back, but some services failed`)
+}
+
+// flags returns a flag set for the command that writes its errors to stderr
+func (c cli) flags(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = c.usage
+
+	return flags
+}
+
+// get writes the report for each zipcode
+func (c cli) get(args []string) int {
+	flags := c.flags("get")
+	format := flags.String("format", formatTable, "The output format: table, json, ndjson or csv")
+	strategy := flags.String("strategy", "", "How to combine the services: first, consensus or hedged")
+	merge := flags.String("merge", "", "How the consensus strategy merges each day: median or mean")
+	kinds := flags.String("kinds", "", "The kinds of forecasts to get (comma separated): pollen, asthma and/or coldflu")
+	services := flags.String("services", "", "The providers to call, in order (comma separated -- see 'pollen providers')")
+	timeout := flags.Duration("timeout", 10*time.Second, "How long each zipcode can take")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "pollen: at least one zipcode is required")
+		return exitUsage
+	}
+
+	switch *format {
+	case formatTable, formatJSON, formatNDJSON, formatCSV:
+	default:
+		fmt.Fprintf(c.stderr, "pollen: unknown format '%s'\n", *format)
+		return exitUsage
+	}
+
+	//	Get the report for each zipcode
+	results := []cliResult{}
+	exitCode := exitOK
+	for _, zipcode := range flags.Args() {
+		msg := queryMessage(map[string][]string{"kinds": {*kinds}, "services": {*services}}, zipcode)
+		msg.Strategy = *strategy
+		msg.Merge = *merge
+
+		result, code := c.getResult(msg, *timeout)
+		results = append(results, result)
+
+		//	Report the worst thing that happened
+		if exitSeverity[code] > exitSeverity[exitCode] {
+			exitCode = code
+		}
+
+		//	The JSON formats include the errors -- the others just write them to stderr
+		if result.Error != "" && (*format == formatTable || *format == formatCSV) {
+			fmt.Fprintf(c.stderr, "pollen: %s: %s\n", zipcode, result.Error)
+		}
+
+		//	Stream newline delimited JSON as we go
+		if *format == formatNDJSON {
+			json.NewEncoder(c.stdout).Encode(result)
+		}
+	}
+
+	switch *format {
+	case formatTable:
+		writeReportTable(c.stdout, results)
+	case formatJSON:
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	case formatCSV:
+		writeReportCSV(c.stdout, results)
+	}
+
+	return exitCode
+}
+
+// getResult gets the report for the message, and the exit code for it
+func (c cli) getResult(msg Message, timeout time.Duration) (cliResult, int) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ctx, seg := xray.BeginSegment(ctx, "pollen-cli")
+	report, err := c.getReport(ctx, msg)
+	seg.Close(err)
+
+	result := cliResult{Zipcode: msg.Zipcode}
+	switch e := err.(type) {
+	case nil:
+		result.Report = &report
+		if degraded(report) {
+			return result, exitDegraded
+		}
+		return result, exitOK
+
+	case *requestError:
+		result.Error = e.Error()
+		return result, exitUsage
+	}
+
+	body := errorBody(err)
+	result.Error = body.Error
+	result.Providers = body.Providers
+
+	return result, exitFailure
+}
+
+// degraded returns true if some of the services the report was built from failed
+func degraded(report data.PollenReport) bool {
+	for _, source := range report.Sources {
+		if source.Error != "" {
+			return true
+		}
+	}
+
+	for _, forecast := range report.Forecasts {
+		if forecast.Error != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeReportTable writes the reports as a table with a row for each day
+func writeReportTable(w io.Writer, results []cliResult) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ZIP\tLOCATION\tSERVICE\tDATE\tINDEX\tCATEGORY\tPREDOMINANT POLLEN")
+
+	for _, result := range results {
+		if result.Report == nil {
+			continue
+		}
+
+		report := result.Report
+		for i, day := range reportDays(*report) {
+			if i == 0 {
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", result.Zipcode, report.Location, report.ReportingService, day.Date, formatIndex(day.Index), day.Category, report.PredominantPollen)
+				continue
+			}
+			fmt.Fprintf(table, "\t\t\t%s\t%s\t%s\t\n", day.Date, formatIndex(day.Index), day.Category)
+		}
+	}
+
+	table.Flush()
+}
+
+// writeReportCSV writes the reports as CSV with a row for each day
+func writeReportCSV(w io.Writer, results []cliResult) {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"zipcode", "location", "service", "date", "index", "normalized", "category", "predominant_pollen"})
+
+	for _, result := range results {
+		if result.Report == nil {
+			continue
+		}
+
+		report := result.Report
+		for _, day := range reportDays(*report) {
+			writer.Write([]string{
+				result.Zipcode,
+				report.Location,
+				report.ReportingService,
+				day.Date,
+				formatIndex(day.Index),
+				formatIndex(day.Normalized),
+				string(day.Category),
+				report.PredominantPollen,
+			})
+		}
+	}
+
+	writer.Flush()
+}
+
+// reportDays returns the days in the report.  If the report doesn't have them,
+// they're made from the data (with just the index)
+func reportDays(report data.PollenReport) []data.ForecastDay {
+	if len(report.Days) > 0 {
+		return report.Days
+	}
+
+	days := []data.ForecastDay{}
+	for i, index := range report.Data {
+		days = append(days, data.ForecastDay{Date: fmt.Sprintf("day %d", i+1), Index: index})
+	}
+
+	return days
+}
+
+// formatIndex formats an index without trailing zeros
+func formatIndex(index float64) string {
+	return strconv.FormatFloat(index, 'f', -1, 64)
+}
+
+// providers writes the active providers and what they can do
+func (c cli) providers(args []string) int {
+	flags := c.flags("providers")
+	format := flags.String("format", formatTable, "The output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	infos := []providerInfo{}
+	for _, provider := range c.active {
+		service := provider.Service
+		info := providerInfo{ProviderInfo: provider.Info, Service: data.ServiceName(service), Kinds: []data.ForecastKind{data.KindPollen}}
+
+		if scaled, ok := service.(data.ScaledService); ok {
+			scale := scaled.Scale()
+			info.Scale = &scale
+		}
+		if forecaster, ok := service.(data.ForecastService); ok {
+			info.Kinds = forecaster.ForecastKinds()
+		}
+		_, info.History = service.(data.HistoryProvider)
+		_, info.Raw = service.(data.RawProvider)
+
+		infos = append(infos, info)
+	}
+
+	switch *format {
+	case formatTable:
+		table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "NAME\tSERVICE\tDAYS\tCOUNTRIES\tSPECIES\tAPI KEY\tSCALE\tKINDS\tHISTORY\tRAW")
+		for _, info := range infos {
+			scale := "-"
+			if info.Scale != nil {
+				scale = fmt.Sprintf("%s-%s %s", formatIndex(info.Scale.Min), formatIndex(info.Scale.Max), info.Scale.Units)
+			}
+
+			kinds := []string{}
+			for _, kind := range info.Kinds {
+				kinds = append(kinds, string(kind))
+			}
+
+			countries := "any"
+			if len(info.Countries) > 0 {
+				countries = strings.Join(info.Countries, ",")
+			}
+
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, info.Service, info.ForecastDays, countries, yesNo(info.Species), yesNo(info.RequiresAPIKey),
+				scale, strings.Join(kinds, ","), yesNo(info.History), yesNo(info.Raw))
+		}
+		table.Flush()
+
+	case formatJSON:
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(infos)
+
+	default:
+		fmt.Fprintf(c.stderr, "pollen: unknown format '%s'\n", *format)
+		return exitUsage
+	}
+
+	return exitOK
+}
+
+// yesNo formats a flag for a table
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// raw writes the upstream API responses for one service as-is
+func (c cli) raw(args []string) int {
+	flags := c.flags("raw")
+	timeout := flags.Duration("timeout", 10*time.Second, "How long the service can take")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() != 2 {
+		fmt.Fprintln(c.stderr, "pollen: a provider and a zipcode are required")
+		return exitUsage
+	}
+	name, zipcode := flags.Arg(0), flags.Arg(1)
+
+	//	Find the provider's service
+	var provider data.RawProvider
+	for _, active := range c.active {
+		if strings.EqualFold(active.Info.Name, strings.TrimSpace(name)) {
+			provider, _ = active.Service.(data.RawProvider)
+			if provider == nil {
+				fmt.Fprintf(c.stderr, "pollen: %s can't return its raw responses\n", active.Info.Name)
+				return exitUsage
+			}
+		}
+	}
+
+	if provider == nil {
+		fmt.Fprintf(c.stderr, "pollen: unknown provider '%s' -- see 'pollen providers'\n", name)
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	ctx, seg := xray.BeginSegment(ctx, "pollen-cli")
+	payloads, err := provider.GetRawReport(ctx, zipcode)
+	seg.Close(err)
+
+	if err != nil {
+		fmt.Fprintf(c.stderr, "pollen: %s: %v\n", zipcode, err)
+		return exitFailure
+	}
+
+	//	Say where each payload came from on stderr, so stdout is just the payloads
+	for _, payload := range payloads {
+		fmt.Fprintf(c.stderr, "# %s %s\n", payload.Name, payload.URL)
+		c.stdout.Write(payload.Body)
+		if !strings.HasSuffix(string(payload.Body), "\n") {
+			fmt.Fprintln(c.stdout)
+		}
+	}
+
+	return exitOK
+}
+
+// dryRun validates the provider config and writes the report one of its
+// services extracts from a sample payload (without calling the service)
+func (c cli) dryRun(args []string) int {
+	flags := c.flags("dry-run")
+	config := flags.String("config", os.Getenv("POLLEN_PROVIDER_CONFIG"), "The provider config file (or set POLLEN_PROVIDER_CONFIG)")
+	name := flags.String("provider", "", "The provider to try (optional if the config only has one)")
+	zipcode := flags.String("zip", "00000", "The zipcode the payload is for")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *config == "" || flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "pollen: a provider config and a sample payload are required")
+		return exitUsage
+	}
+
+	services, err := data.LoadProviderConfig(*config)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "pollen: %v\n", err)
+		return exitUsage
+	}
+
+	//	Find the service
+	var service *data.ConfigurablePollenService
+	for _, configured := range services {
+		if strings.EqualFold(configured.Name(), *name) || (*name == "" && len(services) == 1) {
+			service = configured
+		}
+	}
+
+	if service == nil {
+		names := []string{}
+		for _, configured := range services {
+			names = append(names, configured.Name())
+		}
+		fmt.Fprintf(c.stderr, "pollen: pick a provider with -provider (the config has: %s)\n", strings.Join(names, ", "))
+		return exitUsage
+	}
+
+	payload, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(c.stderr, "pollen: %v\n", err)
+		return exitUsage
+	}
+
+	report, err := service.Extract(*zipcode, payload)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "pollen: %v\n", err)
+		return exitFailure
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	return exitOK
+}
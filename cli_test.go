@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danesparza/pollen/data"
+)
+
+// cliReport is a fake reportFunc for the command line client.  Zipcode 00000
+// fails as if none of the services came through, and 11111 comes back with a
+// failed source
+func cliReport(ctx context.Context, msg Message) (data.PollenReport, error) {
+	switch msg.Zipcode {
+	case "00000":
+		return data.PollenReport{}, &data.MultiProviderError{Zipcode: msg.Zipcode, Errors: []*data.ProviderError{
+			{Service: "Nasacort", Reason: data.FailureHTTPStatus, StatusCode: 503},
+		}}
+	case "bad":
+		return data.PollenReport{}, &requestError{errors.New("bad request")}
+	}
+
+	report := data.PollenReport{
+		Zipcode:           msg.Zipcode,
+		Location:          "DACULA, GA",
+		ReportingService:  "Pollen.com",
+		PredominantPollen: "Juniper, Oak",
+		Data:              []float64{9.7, 2},
+		Days: []data.ForecastDay{
+			{Date: "2019-04-18", Index: 9.7, Normalized: 9.7, Category: data.CategoryHigh},
+			{Date: "2019-04-19", Index: 2, Normalized: 2, Category: data.CategoryLow},
+		},
+	}
+	if msg.Zipcode == "11111" {
+		report.Sources = []data.SourceReport{{Service: "Nasacort", Error: "timed out"}}
+	}
+
+	return report, nil
+}
+
+// runCLI runs the command line client with the args and returns the exit code and output
+func runCLI(providers []data.SelectedProvider, args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	client := cli{getReport: cliReport, active: providers, stdout: stdout, stderr: stderr}
+
+	code := client.run(args)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI_Get_ReturnsExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"success", []string{"get", "30019"}, exitOK},
+		{"degraded", []string{"get", "30019", "11111"}, exitDegraded},
+		{"services failed", []string{"get", "30019", "00000", "11111"}, exitFailure},
+		{"bad request", []string{"get", "bad", "00000"}, exitUsage},
+		{"no zipcode", []string{"get"}, exitUsage},
+		{"unknown format", []string{"get", "-format", "xml", "30019"}, exitUsage},
+		{"unknown command", []string{"set", "30019"}, exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//	Act
+			code, _, stderr := runCLI(nil, tt.args...)
+
+			//	Assert
+			if code != tt.expected {
+				t.Errorf("Expected exit code %d, but got %d: %s", tt.expected, code, stderr)
+			}
+		})
+	}
+}
+
+func TestCLI_Get_WritesTable(t *testing.T) {
+	//	Act
+	code, stdout, stderr := runCLI(nil, "get", "30019", "00000")
+
+	//	Assert
+	if code != exitFailure {
+		t.Errorf("Expected exit code %d, but got %d", exitFailure, code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ZIP") {
+		t.Fatalf("Expected a header and a row for each day, but got:\n%s", stdout)
+	}
+
+	if fields := strings.Fields(lines[1]); fields[0] != "30019" || fields[4] != "2019-04-18" || fields[5] != "9.7" {
+		t.Errorf("Unexpected first row: %s", lines[1])
+	}
+
+	if !strings.Contains(stderr, "00000") {
+		t.Errorf("Expected the failure on stderr, but got %q", stderr)
+	}
+}
+
+func TestCLI_Get_WritesJSONFormats(t *testing.T) {
+	//	Act
+	_, jsonOut, _ := runCLI(nil, "get", "-format", "json", "30019", "00000")
+	_, ndjsonOut, _ := runCLI(nil, "get", "-format", "ndjson", "30019", "00000")
+
+	//	Assert
+	results := []cliResult{}
+	if err := json.Unmarshal([]byte(jsonOut), &results); err != nil || len(results) != 2 {
+		t.Fatalf("Expected a JSON array with each result, but got %s (%v)", jsonOut, err)
+	}
+
+	if results[0].Report == nil || results[0].Report.Location != "DACULA, GA" || results[1].Error == "" || len(results[1].Providers) != 1 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(ndjsonOut))
+	lines := 0
+	for scanner.Scan() {
+		result := cliResult{}
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Errorf("Expected each line to be a JSON result, but got %s", scanner.Text())
+		}
+		lines++
+	}
+
+	if lines != 2 {
+		t.Errorf("Expected a line for each zipcode, but got %d", lines)
+	}
+}
+
+func TestCLI_Get_WritesCSV(t *testing.T) {
+	//	Act
+	code, stdout, _ := runCLI(nil, "get", "-format", "csv", "30019")
+
+	//	Assert
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil || code != exitOK {
+		t.Fatalf("Expected CSV, but got %s (%v)", stdout, err)
+	}
+
+	if len(records) != 3 || records[0][0] != "zipcode" {
+		t.Fatalf("Expected a header and a row for each day, but got %v", records)
+	}
+
+	if records[1][3] != "2019-04-18" || records[1][6] != "High" || records[1][7] != "Juniper, Oak" {
+		t.Errorf("Unexpected first row: %v", records[1])
+	}
+}
+
+func TestCLI_Providers_ListsServices(t *testing.T) {
+	//	Arrange
+	providers := []data.SelectedProvider{
+		{Info: data.ProviderInfo{Name: "nasacort", ForecastDays: 4, Countries: []string{"US"}}, Service: data.NasacortService{}},
+		{Info: data.ProviderInfo{Name: "pollencom", ForecastDays: 4, Countries: []string{"US"}}, Service: data.PollencomService{}},
+	}
+
+	//	Act
+	code, stdout, _ := runCLI(providers, "providers", "-format", "json")
+	tableCode, table, _ := runCLI(providers, "providers")
+
+	//	Assert
+	infos := []providerInfo{}
+	if err := json.Unmarshal([]byte(stdout), &infos); err != nil || code != exitOK {
+		t.Fatalf("Expected the providers as JSON, but got %s (%v)", stdout, err)
+	}
+
+	if len(infos) != 2 || infos[0].Name != "nasacort" || infos[0].Service != "Nasacort" || infos[0].ForecastDays != 4 || infos[0].History || !infos[0].Raw {
+		t.Errorf("Unexpected providers: %+v", infos)
+	}
+
+	if infos[1].Name != "pollencom" || infos[1].Service != "Pollen.com" || infos[1].Countries[0] != "US" || !infos[1].History || len(infos[1].Kinds) != 3 || infos[1].Scale == nil {
+		t.Errorf("Unexpected Pollen.com provider: %+v", infos[1])
+	}
+
+	if tableCode != exitOK || !strings.Contains(table, "pollencom") || !strings.Contains(table, "API KEY") {
+		t.Errorf("Expected the providers as a table, but got %d: %s", tableCode, table)
+	}
+}
+
+func TestCLI_Raw_WritesUpstreamPayload(t *testing.T) {
+	//	Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"response":{"status":"ok"}}`))
+	}))
+	defer server.Close()
+
+	providers := []data.SelectedProvider{
+		{Info: data.ProviderInfo{Name: "pollencom"}, Service: data.PollencomService{Client: server.Client(), BaseURL: server.URL}},
+	}
+
+	//	Act
+	code, stdout, stderr := runCLI(providers, "raw", "pollencom", "30019")
+	_, _, unknown := runCLI(providers, "raw", "Pollen.com", "30019")
+
+	//	Assert
+	if code != exitOK || stdout != "{\"response\":{\"status\":\"ok\"}}\n{\"response\":{\"status\":\"ok\"}}\n" {
+		t.Errorf("Expected the extended and current payloads as-is, but got %d: %s", code, stdout)
+	}
+
+	if !strings.Contains(stderr, server.URL) {
+		t.Errorf("Expected the url on stderr, but got %q", stderr)
+	}
+
+	if !strings.Contains(unknown, "unknown provider") {
+		t.Errorf("Expected an unknown provider error, but got %q", unknown)
+	}
+}
+
+func TestCLI_DryRun_WritesExtractedReport(t *testing.T) {
+	//	Arrange
+	os.Setenv("REGIONAL_POLLEN_TOKEN", "dry-run-token")
+	defer os.Unsetenv("REGIONAL_POLLEN_TOKEN")
+
+	config := filepath.Join("data", "testdata", "configurable", "providers.json")
+	payload := filepath.Join("data", "testdata", "configurable", "regional_30019.json")
+
+	//	Act
+	code, stdout, stderr := runCLI(nil, "dry-run", "-config", config, "-zip", "30019", payload)
+	badCode, _, badStderr := runCLI(nil, "dry-run", "-config", config, config)
+
+	//	Assert
+	report := data.PollenReport{}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil || code != exitOK {
+		t.Fatalf("Expected the extracted report as JSON, but got %d: %s %s (%v)", code, stdout, stderr, err)
+	}
+
+	if report.ReportingService != "Regional Pollen" || report.Zipcode != "30019" || len(report.Days) != 3 || report.PredominantPollen != "Oak, Birch, Grass" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	//	The config isn't a pollen feed, so there's nothing to extract
+	if badCode != exitFailure || !strings.Contains(badStderr, "insufficient_data") {
+		t.Errorf("Expected an insufficient data failure, but got %d: %s", badCode, badStderr)
+	}
+}
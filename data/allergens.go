@@ -0,0 +1,198 @@
+package data
+
+import "strings"
+
+// PlantType is the kind of plant a pollen allergen comes from
+type PlantType string
+
+const (
+	// PlantTree is tree pollen
+	PlantTree PlantType = "Tree"
+
+	// PlantGrass is grass pollen
+	PlantGrass PlantType = "Grass"
+
+	// PlantRagweed is ragweed pollen
+	PlantRagweed PlantType = "Ragweed"
+
+	// PlantWeed is pollen from weeds (other than ragweed)
+	PlantWeed PlantType = "Weed"
+)
+
+// parsePlantType returns the plant type for a (case insensitive) name
+func parsePlantType(name string) PlantType {
+	for _, plantType := range []PlantType{PlantTree, PlantGrass, PlantRagweed, PlantWeed} {
+		if strings.EqualFold(strings.TrimSpace(name), string(plantType)) {
+			return plantType
+		}
+	}
+
+	return PlantType(strings.TrimSpace(name))
+}
+
+// Allergen is a single pollen allergen
+type Allergen struct {
+	Name      string    `json:"name"`                 // The common name
+	Genus     string    `json:"genus,omitempty"`      // The genus (or family, for grasses)
+	PlantType PlantType `json:"plant_type,omitempty"` // The kind of plant
+}
+
+// PlantIndices are the pollen sub-indices by kind of plant, for services that report them
+type PlantIndices struct {
+	Tree  *float64 `json:"tree,omitempty"`  // The tree pollen index
+	Grass *float64 `json:"grass,omitempty"` // The grass pollen index
+	Weed  *float64 `json:"weed,omitempty"`  // The weed (including ragweed) pollen index
+}
+
+// PlantDescription describes a plant whose pollen is in the report
+type PlantDescription struct {
+	Allergen
+	Index          *float64 `json:"index,omitempty"`           // Today's index for the plant, on the service's native scale (if it's in season)
+	Family         string   `json:"family,omitempty"`          // The plant's family
+	Season         string   `json:"season,omitempty"`          // When the plant releases pollen
+	SpecialColors  string   `json:"special_colors,omitempty"`  // What colors to look for
+	SpecialShapes  string   `json:"special_shapes,omitempty"`  // What shapes to look for
+	CrossReaction  string   `json:"cross_reaction,omitempty"`  // What else people allergic to the plant might react to
+	Picture        string   `json:"picture,omitempty"`         // A picture of the plant
+	PictureCloseup string   `json:"picture_closeup,omitempty"` // A close-up picture of the plant
+}
+
+// allergenCatalog is the canonical list of allergens, keyed by lowercase common
+// name.  It's based on the triggers Pollen.com reports
+var allergenCatalog = map[string]Allergen{}
+
+func init() {
+	for _, allergen := range []Allergen{
+		{"Alder", "Alnus", PlantTree},
+		{"Ash", "Fraxinus", PlantTree},
+		{"Aspen", "Populus", PlantTree},
+		{"Beech", "Fagus", PlantTree},
+		{"Birch", "Betula", PlantTree},
+		{"Box Elder", "Acer", PlantTree},
+		{"Cedar", "Juniperus", PlantTree},
+		{"Cottonwood", "Populus", PlantTree},
+		{"Cypress", "Cupressus", PlantTree},
+		{"Elm", "Ulmus", PlantTree},
+		{"Hazelnut", "Corylus", PlantTree},
+		{"Hickory", "Carya", PlantTree},
+		{"Juniper", "Juniperus", PlantTree},
+		{"Maple", "Acer", PlantTree},
+		{"Mulberry", "Morus", PlantTree},
+		{"Oak", "Quercus", PlantTree},
+		{"Olive", "Olea", PlantTree},
+		{"Pecan", "Carya", PlantTree},
+		{"Pine", "Pinus", PlantTree},
+		{"Poplar", "Populus", PlantTree},
+		{"Sweetgum", "Liquidambar", PlantTree},
+		{"Sycamore", "Platanus", PlantTree},
+		{"Walnut", "Juglans", PlantTree},
+		{"Willow", "Salix", PlantTree},
+		{"Bermuda Grass", "Cynodon", PlantGrass},
+		{"Grass", "Poaceae", PlantGrass},
+		{"Johnson Grass", "Sorghum", PlantGrass},
+		{"Orchard Grass", "Dactylis", PlantGrass},
+		{"Rye Grass", "Lolium", PlantGrass},
+		{"Timothy Grass", "Phleum", PlantGrass},
+		{"Ragweed", "Ambrosia", PlantRagweed},
+		{"Chenopods", "Chenopodium", PlantWeed},
+		{"Dock", "Rumex", PlantWeed},
+		{"Lamb's Quarters", "Chenopodium", PlantWeed},
+		{"Mugwort", "Artemisia", PlantWeed},
+		{"Nettle", "Urtica", PlantWeed},
+		{"Pigweed", "Amaranthus", PlantWeed},
+		{"Plantain", "Plantago", PlantWeed},
+		{"Sagebrush", "Artemisia", PlantWeed},
+		{"Sorrel", "Rumex", PlantWeed},
+	} {
+		allergenCatalog[strings.ToLower(allergen.Name)] = allergen
+	}
+}
+
+// LookupAllergen returns the canonical allergen for a common name.  If the name
+// isn't in the catalog, an allergen with just the (trimmed) name is returned
+func LookupAllergen(name string) Allergen {
+	name = strings.TrimSpace(name)
+
+	if allergen, ok := allergenCatalog[strings.ToLower(name)]; ok {
+		return allergen
+	}
+
+	return Allergen{Name: name}
+}
+
+// ParseAllergens splits a free-text list of pollens (like "Oak, Birch and
+// Sycamore.") into the canonical allergen for each one
+func ParseAllergens(text string) []Allergen {
+	//	Treat 'and' (and '&') the same as a comma
+	normalized := " " + strings.TrimSpace(text) + " "
+	for _, separator := range []string{" and ", " And ", " AND ", " & ", ";", "/"} {
+		normalized = strings.Replace(normalized, separator, ",", -1)
+	}
+
+	retval := []Allergen{}
+	for _, name := range strings.Split(normalized, ",") {
+		name = strings.Trim(name, " \t\r\n.!;:")
+		if name == "" {
+			continue
+		}
+
+		retval = append(retval, lookupPlural(name))
+	}
+
+	return mergeAllergens(retval)
+}
+
+// lookupPlural looks up the allergen for the name, trying the singular form if
+// the plural isn't in the catalog (so "Grasses" is the same as "Grass")
+func lookupPlural(name string) Allergen {
+	allergen := LookupAllergen(name)
+	if allergen.PlantType != "" {
+		return allergen
+	}
+
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{"es", "s"} {
+		if strings.HasSuffix(lower, suffix) {
+			if singular := LookupAllergen(name[:len(name)-len(suffix)]); singular.PlantType != "" {
+				return singular
+			}
+		}
+	}
+
+	return allergen
+}
+
+// canonicalAllergen fills in anything the allergen is missing from the catalog
+// (if the catalog has it)
+func canonicalAllergen(allergen Allergen) Allergen {
+	known := LookupAllergen(allergen.Name)
+
+	if allergen.Genus == "" {
+		allergen.Genus = known.Genus
+	}
+	if allergen.PlantType == "" {
+		allergen.PlantType = known.PlantType
+	}
+
+	return allergen
+}
+
+// mergeAllergens returns the union of the allergen lists (by name, keeping the
+// order they were first seen in)
+func mergeAllergens(lists ...[]Allergen) []Allergen {
+	seen := map[string]bool{}
+	retval := []Allergen{}
+
+	for _, list := range lists {
+		for _, allergen := range list {
+			key := strings.ToLower(allergen.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			retval = append(retval, allergen)
+		}
+	}
+
+	return retval
+}
@@ -0,0 +1,54 @@
+package data_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danesparza/pollen/data"
+)
+
+func TestLookupAllergen_ReturnsCanonicalAllergen(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected data.Allergen
+	}{
+		{"Oak", data.Allergen{Name: "Oak", Genus: "Quercus", PlantType: data.PlantTree}},
+		{" bermuda grass ", data.Allergen{Name: "Bermuda Grass", Genus: "Cynodon", PlantType: data.PlantGrass}},
+		{"RAGWEED", data.Allergen{Name: "Ragweed", Genus: "Ambrosia", PlantType: data.PlantRagweed}},
+		{"Mugwort", data.Allergen{Name: "Mugwort", Genus: "Artemisia", PlantType: data.PlantWeed}},
+		{"Ironwood ", data.Allergen{Name: "Ironwood"}},
+	}
+
+	for _, tt := range tests {
+		if allergen := data.LookupAllergen(tt.name); allergen != tt.expected {
+			t.Errorf("LookupAllergen(%q) = %+v, expected %+v", tt.name, allergen, tt.expected)
+		}
+	}
+}
+
+func TestParseAllergens_SplitsFreeText(t *testing.T) {
+	oak := data.Allergen{Name: "Oak", Genus: "Quercus", PlantType: data.PlantTree}
+	birch := data.Allergen{Name: "Birch", Genus: "Betula", PlantType: data.PlantTree}
+	sycamore := data.Allergen{Name: "Sycamore", Genus: "Platanus", PlantType: data.PlantTree}
+	grass := data.Allergen{Name: "Grass", Genus: "Poaceae", PlantType: data.PlantGrass}
+	ragweed := data.Allergen{Name: "Ragweed", Genus: "Ambrosia", PlantType: data.PlantRagweed}
+
+	tests := []struct {
+		text     string
+		expected []data.Allergen
+	}{
+		{"Oak, Birch and Sycamore.", []data.Allergen{oak, birch, sycamore}},
+		{"Oak, Birch, and Sycamore", []data.Allergen{oak, birch, sycamore}},
+		{"grasses & ragweed!", []data.Allergen{grass, ragweed}},
+		{"Oak and oak.", []data.Allergen{oak}},
+		{"Oak and Ironwood.", []data.Allergen{oak, {Name: "Ironwood"}}},
+		{" . ", []data.Allergen{}},
+		{"", []data.Allergen{}},
+	}
+
+	for _, tt := range tests {
+		if allergens := data.ParseAllergens(tt.text); !reflect.DeepEqual(allergens, tt.expected) {
+			t.Errorf("ParseAllergens(%q) = %+v, expected %+v", tt.text, allergens, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// MaxSeriesDays is the most days a series can cover (each day is a separate
+// read from the archive)
+const MaxSeriesDays = 366
+
+// ArchivedReport is a served report, as archived for the day it was served on
+type ArchivedReport struct {
+	Zipcode    string       `json:"zip"`         // The zipcode for the report
+	Date       string       `json:"date"`        // The calendar date (YYYY-MM-DD) in the zipcode's timezone the report was served on
+	ArchivedAt time.Time    `json:"archived_at"` // When the report was archived
+	Report     PollenReport `json:"report"`      // The report
+}
+
+// ReportStore is where archived reports are kept.  There's one report for each
+// zipcode on each day
+type ReportStore interface {
+	// SaveReport archives the report, unless there's already one for its zipcode
+	// and date.  saved is false if there was
+	SaveReport(ctx context.Context, archived ArchivedReport) (saved bool, err error)
+
+	// LoadReport gets the archived report for the zipcode and date.  If there
+	// isn't one, ok is false
+	LoadReport(ctx context.Context, zipcode, date string) (archived ArchivedReport, ok bool, err error)
+}
+
+// SeriesDay is the pollen index archived for a day
+type SeriesDay struct {
+	ForecastDay
+	Service string `json:"service"` // The service that reported the index
+}
+
+// IndexSeries is the day by day pollen index archived for a zipcode
+type IndexSeries struct {
+	Zipcode string      `json:"zip"`   // The zipcode for the series
+	Start   string      `json:"start"` // The first calendar date (YYYY-MM-DD) in the range
+	End     string      `json:"end"`   // The last calendar date (YYYY-MM-DD) in the range
+	Days    []SeriesDay `json:"days"`  // The index for each day that has an archived report, oldest first
+}
+
+// Archive keeps every served report (once per zipcode per day) so pollen
+// trends can be analyzed over whole seasons
+type Archive struct {
+	Store ReportStore      // Where the reports are kept
+	Now   func() time.Time // The current time (optional -- defaults to time.Now)
+
+	mu    sync.Mutex
+	saved map[string]string // The date each zipcode was last saved on (so the store isn't asked again that day)
+}
+
+// Strategy returns the strategy with the reports it gets archived
+func (a *Archive) Strategy(strategy Strategy) Strategy {
+	return archivedStrategy{archive: a, strategy: strategy}
+}
+
+// archivedStrategy is a strategy with its reports archived
+type archivedStrategy struct {
+	archive  *Archive
+	strategy Strategy
+}
+
+// Unwrap returns the strategy that's archived
+func (s archivedStrategy) Unwrap() Strategy {
+	return s.strategy
+}
+
+// Aggregate gets the report with the strategy, and archives it
+func (s archivedStrategy) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	report, err := s.strategy.Aggregate(ctx, services, zipcode)
+	if err != nil || report.Stale {
+		return report, err
+	}
+
+	//	A problem archiving the report isn't a problem with the report
+	if err := s.archive.saveReport(ctx, zipcode, servicesLocation(services, zipcode), report); err != nil {
+		xray.AddMetadata(ctx, "ArchiveError", err.Error())
+	}
+
+	return report, nil
+}
+
+// SaveReport archives the report for the zipcode on today's date in the
+// zipcode's timezone, unless one was already archived today
+func (a *Archive) SaveReport(ctx context.Context, zipcode string, report PollenReport) error {
+	return a.saveReport(ctx, zipcode, zipcodeLocation(zipcode), report)
+}
+
+// saveReport archives the report for the zipcode on today's date in the
+// location, unless one was already archived today
+func (a *Archive) saveReport(ctx context.Context, zipcode string, location *time.Location, report PollenReport) error {
+	now := time.Now()
+	if a.Now != nil {
+		now = a.Now()
+	}
+
+	if err := ValidateZipcode(zipcode); err != nil {
+		return err
+	}
+
+	zipcode = NormalizeZipcode(zipcode)
+	date := now.In(location).Format(DateFormat)
+
+	a.mu.Lock()
+	if a.saved == nil {
+		a.saved = map[string]string{}
+	}
+	done := a.saved[zipcode] == date
+	a.mu.Unlock()
+
+	if done {
+		return nil
+	}
+
+	//	Archive it as it was fetched
+	report.CacheHit = false
+	if _, err := a.Store.SaveReport(ctx, ArchivedReport{Zipcode: zipcode, Date: date, ArchivedAt: now, Report: report}); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.saved[zipcode] = date
+	a.mu.Unlock()
+
+	return nil
+}
+
+// SeriesRange fills in the defaults for a series date range (the same ones as
+// HistoryRange).  It's an error for the range to start after it ends, or to
+// cover more than MaxSeriesDays days
+func SeriesRange(start, end time.Time) (time.Time, time.Time, error) {
+	start, end, err := HistoryRange(start, end)
+	if err != nil {
+		return start, end, err
+	}
+
+	first, _ := time.Parse(DateFormat, start.Format(DateFormat))
+	last, _ := time.Parse(DateFormat, end.Format(DateFormat))
+	if days := int(last.Sub(first).Hours()/24) + 1; days > MaxSeriesDays {
+		return start, end, fmt.Errorf("The series covers %d days -- it can't be more than %d", days, MaxSeriesDays)
+	}
+
+	return start, end, nil
+}
+
+// GetIndexSeries returns the archived pollen index for each day from start to
+// end (inclusive).  Days without an archived report are left out.  If start and
+// end are zero, the last HistoryDays days are returned.  The range is checked
+// with SeriesRange
+func GetIndexSeries(ctx context.Context, store ReportStore, zipcode string, start, end time.Time) (IndexSeries, error) {
+	start, end, err := SeriesRange(start, end)
+	if err != nil {
+		return IndexSeries{}, err
+	}
+
+	if err := ValidateZipcode(zipcode); err != nil {
+		return IndexSeries{}, err
+	}
+
+	zipcode = NormalizeZipcode(zipcode)
+	first := start.Format(DateFormat)
+	last := end.Format(DateFormat)
+
+	retval := IndexSeries{Zipcode: zipcode, Start: first, End: last, Days: []SeriesDay{}}
+	for day := start; day.Format(DateFormat) <= last; day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateFormat)
+
+		archived, ok, err := store.LoadReport(ctx, zipcode, date)
+		if err != nil {
+			return IndexSeries{}, err
+		}
+		if !ok {
+			continue
+		}
+
+		if forecast, ok := archivedDay(archived); ok {
+			retval.Days = append(retval.Days, SeriesDay{ForecastDay: forecast, Service: archived.Report.ReportingService})
+		}
+	}
+
+	return retval, nil
+}
+
+// archivedDay returns the forecast for the day the report was archived on
+// (falling back to the first index in the report's normalized data).  A report
+// without days that was never normalized is on an unknown scale, so it's left
+// out rather than mixed in with canonical indices
+func archivedDay(archived ArchivedReport) (ForecastDay, bool) {
+	for _, day := range archived.Report.Days {
+		if day.Date == archived.Date {
+			return day, true
+		}
+	}
+
+	report := archived.Report
+	if len(report.Data) > 0 && len(report.Normalized) == len(report.Data) {
+		day := newForecastDay(archived.Date, report.Normalized[0], CanonicalScale)
+		return day, true
+	}
+
+	return ForecastDay{}, false
+}
+
+// archivePath returns the path of the archived report for the zipcode and
+// date, relative to the root of the archive.  Both are checked first, so they
+// can't point outside of it
+func archivePath(zipcode, date string) (string, error) {
+	if err := ValidateZipcode(zipcode); err != nil {
+		return "", err
+	}
+
+	if _, err := time.Parse(DateFormat, date); err != nil {
+		return "", fmt.Errorf("'%s' isn't a valid archive date -- it should be YYYY-MM-DD", date)
+	}
+
+	return fmt.Sprintf("%s/%s.json", NormalizeZipcode(zipcode), date), nil
+}
+
+// LocalReportStore is an embedded archive that keeps each report as a JSON
+// file in a directory for its zipcode
+type LocalReportStore struct {
+	Dir string // The directory for the archive
+}
+
+// SaveReport archives the report, unless there's already one for its zipcode
+// and date.  Reports are written all at once, and never replaced
+func (s LocalReportStore) SaveReport(ctx context.Context, archived ArchivedReport) (bool, error) {
+	relative, err := archivePath(archived.Zipcode, archived.Date)
+	if err != nil {
+		return false, err
+	}
+
+	path := filepath.Join(s.Dir, filepath.FromSlash(relative))
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+
+	contents, err := json.Marshal(archived)
+	if err != nil {
+		return false, fmt.Errorf("There was a problem encoding the archived report: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("There was a problem creating the archive directory: %v", err)
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(path), ".report-")
+	if err != nil {
+		return false, fmt.Errorf("There was a problem writing the archived report: %v", err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(contents)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("There was a problem writing the archived report: %v", err)
+	}
+
+	//	Linking fails if the report is already there, so only one report is ever saved for the day
+	if err := os.Link(temp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("There was a problem writing the archived report: %v", err)
+	}
+
+	return true, nil
+}
+
+// LoadReport gets the archived report for the zipcode and date
+func (s LocalReportStore) LoadReport(ctx context.Context, zipcode, date string) (ArchivedReport, bool, error) {
+	relative, err := archivePath(zipcode, date)
+	if err != nil {
+		return ArchivedReport{}, false, err
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(relative)))
+	if os.IsNotExist(err) {
+		return ArchivedReport{}, false, nil
+	}
+	if err != nil {
+		return ArchivedReport{}, false, fmt.Errorf("There was a problem reading the archived report: %v", err)
+	}
+
+	archived := ArchivedReport{}
+	if err := json.Unmarshal(contents, &archived); err != nil {
+		return ArchivedReport{}, false, fmt.Errorf("There was a problem decoding the archived report: %v", err)
+	}
+
+	return archived, true, nil
+}
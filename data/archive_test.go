@@ -0,0 +1,194 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// newTestArchiveDir returns a temp directory for an archive (and a func to clean it up)
+func newTestArchiveDir(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "pollen-archive-test")
+	if err != nil {
+		t.Fatalf("Unable to create a temp directory: %v", err)
+	}
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestLocalReportStore_SaveReport_SavesOncePerDay(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	store := data.LocalReportStore{Dir: dir}
+	first := data.ArchivedReport{Zipcode: "30019", Date: "2019-04-18", Report: data.PollenReport{Data: []float64{9.1}}}
+	second := data.ArchivedReport{Zipcode: "30019", Date: "2019-04-18", Report: data.PollenReport{Data: []float64{1}}}
+	ctx := context.Background()
+
+	//	Act
+	_, missing, missingErr := store.LoadReport(ctx, "30019", "2019-04-18")
+	firstSaved, firstErr := store.SaveReport(ctx, first)
+	secondSaved, secondErr := store.SaveReport(ctx, second)
+	archived, ok, err := store.LoadReport(ctx, "30019", "2019-04-18")
+
+	//	Assert
+	if missing || missingErr != nil {
+		t.Errorf("Expected no report before it's saved, but got %v (%v)", missing, missingErr)
+	}
+
+	if !firstSaved || firstErr != nil || secondSaved || secondErr != nil {
+		t.Errorf("Expected only the first report to be saved, but got %v (%v) and %v (%v)", firstSaved, firstErr, secondSaved, secondErr)
+	}
+
+	if err != nil || !ok || archived.Report.Data[0] != 9.1 {
+		t.Errorf("Expected the first report, but got %+v, %v (%v)", archived, ok, err)
+	}
+}
+
+func TestArchive_Strategy_ArchivesServedReports(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	var calls int32
+	service := countingService{name: "Counting", calls: &calls, report: data.PollenReport{ReportingService: "Counting", Data: []float64{4.2, 5}}}
+	now := &clock{now: time.Date(2019, 4, 19, 2, 0, 0, 0, time.UTC)}
+	archive := &data.Archive{Store: data.LocalReportStore{Dir: dir}, Now: now.Now}
+	strategy := archive.Strategy(data.FirstResponse{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := strategy.Aggregate(ctx, []data.PollenService{service}, "30019-1234")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected the report, but got %v", err)
+	}
+
+	//	It's still the 18th in Georgia
+	archived, ok, err := data.LocalReportStore{Dir: dir}.LoadReport(ctx, "30019", "2019-04-18")
+	if err != nil || !ok || archived.Report.Data[0] != 4.2 || !archived.ArchivedAt.Equal(now.now) {
+		t.Errorf("Expected the report to be archived for its local date, but got %+v, %v (%v)", archived, ok, err)
+	}
+}
+
+func TestArchive_Strategy_SkipsFailedReports(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	var calls int32
+	failing := countingService{name: "Failing", calls: &calls, err: errors.New("down")}
+	archive := &data.Archive{Store: data.LocalReportStore{Dir: dir}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	archive.Strategy(data.FirstResponse{}).Aggregate(ctx, []data.PollenService{failing}, "30019")
+
+	//	Assert
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected nothing to be archived, but got %d files", len(files))
+	}
+}
+
+func TestGetIndexSeries_ReturnsArchivedDaysInRange(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	store := data.LocalReportStore{Dir: dir}
+	ctx := context.Background()
+
+	store.SaveReport(ctx, data.ArchivedReport{Zipcode: "30019", Date: "2019-04-16", Report: data.PollenReport{ReportingService: "Nasacort", Data: []float64{300}, Normalized: []float64{3}}})
+	store.SaveReport(ctx, data.ArchivedReport{Zipcode: "30019", Date: "2019-04-17", Report: data.PollenReport{ReportingService: "Unscaled", Data: []float64{300}}})
+	store.SaveReport(ctx, data.ArchivedReport{Zipcode: "30019", Date: "2019-04-18", Report: data.PollenReport{
+		ReportingService: "Pollen.com",
+		Data:             []float64{9.1, 8},
+		Days:             []data.ForecastDay{{Date: "2019-04-18", Index: 9.1, Normalized: 9.1}, {Date: "2019-04-19", Index: 8, Normalized: 8}},
+	}})
+	store.SaveReport(ctx, data.ArchivedReport{Zipcode: "30019", Date: "2019-04-20", Report: data.PollenReport{Data: []float64{1}}})
+
+	start := time.Date(2019, 4, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 4, 19, 0, 0, 0, 0, time.UTC)
+
+	//	Act
+	series, err := data.GetIndexSeries(ctx, store, "30019-1234", start, end)
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected the series, but got %v", err)
+	}
+
+	if series.Zipcode != "30019" || series.Start != "2019-04-15" || series.End != "2019-04-19" || len(series.Days) != 2 {
+		t.Fatalf("Expected the two archived days in range (without the unnormalized one), but got %+v", series)
+	}
+
+	if series.Days[0].Date != "2019-04-16" || series.Days[0].Index != 3 || series.Days[0].Normalized != 3 || series.Days[0].Service != "Nasacort" {
+		t.Errorf("Unexpected first day: %+v", series.Days[0])
+	}
+
+	if series.Days[1].Date != "2019-04-18" || series.Days[1].Index != 9.1 || series.Days[1].Service != "Pollen.com" {
+		t.Errorf("Unexpected second day: %+v", series.Days[1])
+	}
+}
+
+func TestLocalReportStore_InvalidZipcode_ReturnsError(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	store := data.LocalReportStore{Dir: dir + "/archive"}
+	archived := data.ArchivedReport{Zipcode: "../../escaped", Date: "2019-04-18", Report: data.PollenReport{Data: []float64{9.1}}}
+	ctx := context.Background()
+
+	//	Act
+	saved, saveErr := store.SaveReport(ctx, archived)
+	_, ok, loadErr := store.LoadReport(ctx, "30019", "../2019-04-18")
+
+	//	Assert
+	if saved || saveErr == nil {
+		t.Errorf("Expected an error saving the report, but got %v (%v)", saved, saveErr)
+	}
+
+	if ok || loadErr == nil {
+		t.Errorf("Expected an error loading the report, but got %v (%v)", ok, loadErr)
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected nothing to be written, but got %d files", len(files))
+	}
+}
+
+func TestGetIndexSeries_RangeTooLong_ReturnsError(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	store := data.LocalReportStore{Dir: dir}
+	start := time.Date(2018, 4, 18, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, data.MaxSeriesDays)
+
+	//	Act
+	_, err := data.GetIndexSeries(context.Background(), store, "30019", start, end)
+	_, _, rangeErr := data.SeriesRange(start, end.AddDate(0, 0, -1))
+
+	//	Assert
+	if err == nil {
+		t.Errorf("Expected an error for a series longer than %d days", data.MaxSeriesDays)
+	}
+
+	if rangeErr != nil {
+		t.Errorf("Expected a %d day series to be allowed, but got %v", data.MaxSeriesDays, rangeErr)
+	}
+}
@@ -0,0 +1,263 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// DefaultCacheTTL is how long cached reports are used when the cache doesn't set a TTL
+const DefaultCacheTTL = 30 * time.Minute
+
+// DefaultCacheSize is how many reports a MemoryStore holds when it doesn't set a size
+const DefaultCacheSize = 1000
+
+// CacheEntry is a cached pollen report
+type CacheEntry struct {
+	Report    PollenReport `json:"report"`     // The cached report
+	FetchedAt time.Time    `json:"fetched_at"` // When the report was fetched from the services
+	ExpiresAt time.Time    `json:"expires_at"` // When the report should be fetched again
+	Failed    bool         `json:"failed"`     // True if the services failed the last time the report was fetched
+}
+
+// CacheStore is where cached reports are kept
+type CacheStore interface {
+	// Get gets the entry for the key (expired or not).  If there isn't one, ok is false
+	Get(ctx context.Context, key string) (entry CacheEntry, ok bool, err error)
+
+	// Set stores the entry for the key
+	Set(ctx context.Context, key string, entry CacheEntry) error
+}
+
+// Cache caches pollen reports by zipcode and the local forecast date, so a
+// zipcode that was just fetched isn't fetched again (and tomorrow's request
+// never gets today's forecast).  Use it to wrap a service or a strategy
+type Cache struct {
+	Store CacheStore       // Where the reports are kept
+	TTL   time.Duration    // How long a report is used (optional -- defaults to DefaultCacheTTL)
+	Now   func() time.Time // The current time (optional -- defaults to time.Now)
+}
+
+// Service returns the service with its reports cached.  The cached service
+// only has the service's name and scale -- not its other capabilities
+func (c Cache) Service(service PollenService) PollenService {
+	return cachedService{cache: c, service: service}
+}
+
+// Strategy returns the strategy with its reports cached.  Reports are cached
+// separately for each strategy (and its settings) and set of services
+func (c Cache) Strategy(strategy Strategy) Strategy {
+	return cachedStrategy{cache: c, strategy: strategy}
+}
+
+// getPollenReport returns the cached report for the zipcode, or fetches (and
+// caches) it if there isn't one.  Errors aren't cached
+func (c Cache) getPollenReport(ctx context.Context, namespace, zipcode string, location *time.Location, fetch func(context.Context) (PollenReport, error)) (PollenReport, error) {
+	now := c.now()
+	key := c.key(namespace, zipcode, location, now)
+
+	entry, ok, err := c.Store.Get(ctx, key)
+	if err != nil {
+		//	If we can't use the cache, just get the report
+		xray.AddMetadata(ctx, "CacheError", err.Error())
+		ok = false
+	}
+
+	if ok && now.Before(entry.ExpiresAt) {
+		xray.AddAnnotation(ctx, "cache_hit", true)
+		report := entry.Report
+		report.CacheHit = true
+		return report, nil
+	}
+
+	xray.AddAnnotation(ctx, "cache_hit", false)
+
+	report, err := fetch(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	if err := c.Store.Set(ctx, key, CacheEntry{Report: report, FetchedAt: now, ExpiresAt: now.Add(c.ttl())}); err != nil {
+		xray.AddMetadata(ctx, "CacheError", err.Error())
+	}
+
+	return report, nil
+}
+
+// key returns the cache key for the zipcode on the current date in the
+// zipcode's timezone (location)
+func (c Cache) key(namespace, zipcode string, location *time.Location, now time.Time) string {
+	zipcode = NormalizeZipcode(zipcode)
+	date := now.In(location).Format(DateFormat)
+
+	return fmt.Sprintf("%s|%s|%s", namespace, zipcode, date)
+}
+
+// ttl returns how long reports are used
+func (c Cache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+
+	return DefaultCacheTTL
+}
+
+// now returns the current time
+func (c Cache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+
+	return time.Now()
+}
+
+// cachedService is a service with its reports cached
+type cachedService struct {
+	cache   Cache
+	service PollenService
+}
+
+// Name returns the name of the cached service
+func (s cachedService) Name() string {
+	return ServiceName(s.service)
+}
+
+// Scale returns the native scale of the cached service
+func (s cachedService) Scale() IndexScale {
+	return ServiceScale(s.service)
+}
+
+// Unwrap returns the service that's cached
+func (s cachedService) Unwrap() PollenService {
+	return s.service
+}
+
+// GetPollenReport gets the cached report, or gets it from the service
+func (s cachedService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	location := servicesLocation([]PollenService{s.service}, zipcode)
+	return s.cache.getPollenReport(ctx, s.Name(), zipcode, location, func(ctx context.Context) (PollenReport, error) {
+		return s.service.GetPollenReport(ctx, zipcode)
+	})
+}
+
+// cachedStrategy is a strategy with its reports cached
+type cachedStrategy struct {
+	cache    Cache
+	strategy Strategy
+}
+
+// Unwrap returns the strategy that's cached
+func (s cachedStrategy) Unwrap() Strategy {
+	return s.strategy
+}
+
+// Aggregate gets the cached report, or gets it with the strategy
+func (s cachedStrategy) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	location := servicesLocation(services, zipcode)
+	return s.cache.getPollenReport(ctx, strategyNamespace(s.strategy, services), zipcode, location, func(ctx context.Context) (PollenReport, error) {
+		return s.strategy.Aggregate(ctx, services, zipcode)
+	})
+}
+
+// strategyNamespace returns the cache namespace for reports from the strategy
+// (and its settings) with the services.  Wrapped strategies (like cached or
+// coalesced strategies) are named for the strategy they wrap -- the wrappers
+// point at things in memory, and the namespace has to be the same across
+// restarts (and Lambda containers) for shared stores
+func strategyNamespace(strategy Strategy, services []PollenService) string {
+	for {
+		wrapper, ok := strategy.(interface{ Unwrap() Strategy })
+		if !ok {
+			break
+		}
+		strategy = wrapper.Unwrap()
+	}
+
+	names := []string{}
+	for _, service := range services {
+		names = append(names, ServiceName(service))
+	}
+
+	return fmt.Sprintf("%T%+v(%s)", strategy, strategy, strings.Join(names, ","))
+}
+
+// MemoryStore keeps cached reports in memory (so they last as long as a warm
+// Lambda container).  When it's full, the least recently used report is dropped.
+// The zero value is ready to use
+type MemoryStore struct {
+	Size int // The most reports to keep (optional -- defaults to DefaultCacheSize)
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+}
+
+// memoryItem is a cached report in a MemoryStore
+type memoryItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// Get gets the entry for the key
+func (m *MemoryStore) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+
+	m.order.MoveToFront(element)
+	return element.Value.(*memoryItem).entry, true, nil
+}
+
+// Set stores the entry for the key, dropping the least recently used entries
+// if the store is full
+func (m *MemoryStore) Set(ctx context.Context, key string, entry CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = map[string]*list.Element{}
+		m.order = list.New()
+	}
+
+	if element, ok := m.entries[key]; ok {
+		element.Value.(*memoryItem).entry = entry
+		m.order.MoveToFront(element)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryItem{key: key, entry: entry})
+
+	size := m.Size
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	for m.order.Len() > size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryItem).key)
+	}
+
+	return nil
+}
+
+// Len returns how many reports are cached
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.order == nil {
+		return 0
+	}
+
+	return m.order.Len()
+}
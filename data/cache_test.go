@@ -0,0 +1,231 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// countingService is a PollenService that counts how many times it's called
+type countingService struct {
+	name   string
+	calls  *int32
+	delay  time.Duration
+	report data.PollenReport
+	err    error
+}
+
+func (s countingService) Name() string {
+	return s.name
+}
+
+func (s countingService) GetPollenReport(ctx context.Context, zipcode string) (data.PollenReport, error) {
+	atomic.AddInt32(s.calls, 1)
+	time.Sleep(s.delay)
+
+	report := s.report
+	report.Zipcode = zipcode
+	return report, s.err
+}
+
+// clock is a settable time for caches
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestCache_Service_ReturnsCachedReport(t *testing.T) {
+	//	Arrange
+	var calls int32
+	now := &clock{now: time.Date(2019, 4, 18, 16, 0, 0, 0, time.UTC)}
+	cache := data.Cache{Store: &data.MemoryStore{}, TTL: time.Hour, Now: now.Now}
+	service := cache.Service(countingService{name: "Counting", calls: &calls, report: data.PollenReport{Data: []float64{1, 2}}})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	first, _ := service.GetPollenReport(ctx, "30019")
+	second, _ := service.GetPollenReport(ctx, " 30019-1234 ")
+	now.now = now.now.Add(59 * time.Minute)
+	third, _ := service.GetPollenReport(ctx, "30019")
+	now.now = now.now.Add(2 * time.Minute)
+	expired, _ := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if first.CacheHit || !second.CacheHit || !third.CacheHit || expired.CacheHit {
+		t.Errorf("Expected a miss, two hits and a miss, but got %v, %v, %v, %v", first.CacheHit, second.CacheHit, third.CacheHit, expired.CacheHit)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected the service to be called twice, but it was called %d times", calls)
+	}
+
+	if data.ServiceName(service) != "Counting" {
+		t.Errorf("Expected the cached service to keep its name, but got %s", data.ServiceName(service))
+	}
+}
+
+func TestCache_Service_KeysByLocalDate(t *testing.T) {
+	//	Arrange
+	var calls int32
+
+	//	11pm in Dacula, GA (but already tomorrow in UTC)
+	now := &clock{now: time.Date(2019, 4, 18, 3, 0, 0, 0, time.UTC)}
+	cache := data.Cache{Store: &data.MemoryStore{}, TTL: 24 * time.Hour, Now: now.Now}
+	service := cache.Service(countingService{name: "Counting", calls: &calls})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	before, _ := service.GetPollenReport(ctx, "30019")
+	now.now = now.now.Add(30 * time.Minute)
+	sameDay, _ := service.GetPollenReport(ctx, "30019")
+	now.now = now.now.Add(time.Hour)
+	nextDay, _ := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if before.CacheHit || !sameDay.CacheHit || nextDay.CacheHit {
+		t.Errorf("Expected a miss, a hit and a miss after local midnight, but got %v, %v, %v", before.CacheHit, sameDay.CacheHit, nextDay.CacheHit)
+	}
+}
+
+// germanService is a countingService for German postal codes
+type germanService struct {
+	countingService
+}
+
+func (s germanService) CountryCode() string {
+	return "DE"
+}
+
+func TestCache_Service_KeysGermanPostalCodesByBerlinDate(t *testing.T) {
+	//	Arrange
+	var calls int32
+
+	//	11:30pm in Berlin (but only 5:30pm in New York, where zipcode 10115 is)
+	now := &clock{now: time.Date(2019, 4, 18, 21, 30, 0, 0, time.UTC)}
+	cache := data.Cache{Store: &data.MemoryStore{}, TTL: 24 * time.Hour, Now: now.Now}
+	service := cache.Service(germanService{countingService{name: "Counting", calls: &calls}})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	before, _ := service.GetPollenReport(ctx, "10115")
+	now.now = now.now.Add(time.Hour)
+	nextDay, _ := service.GetPollenReport(ctx, "10115")
+
+	//	Assert
+	if before.CacheHit || nextDay.CacheHit {
+		t.Errorf("Expected a miss after midnight in Berlin, but got %v, %v", before.CacheHit, nextDay.CacheHit)
+	}
+}
+
+func TestCache_Service_DoesNotCacheErrors(t *testing.T) {
+	//	Arrange
+	var calls int32
+	cache := data.Cache{Store: &data.MemoryStore{}}
+	service := cache.Service(countingService{name: "Broken", calls: &calls, err: errors.New("broken")})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	service.GetPollenReport(ctx, "30019")
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err == nil || calls != 2 {
+		t.Errorf("Expected the error both times (%d calls), but got %v", calls, err)
+	}
+}
+
+func TestCache_Strategy_CachesEachStrategySeparately(t *testing.T) {
+	//	Arrange
+	var calls int32
+	cache := data.Cache{Store: &data.MemoryStore{}}
+	services := []data.PollenService{countingService{name: "Counting", calls: &calls, report: data.PollenReport{Data: []float64{1, 2}}}}
+	first := cache.Strategy(data.FirstResponse{})
+	consensus := cache.Strategy(data.Consensus{Method: data.MergeMean})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	first.Aggregate(ctx, services, "30019")
+	cached, err := first.Aggregate(ctx, services, "30019")
+	merged, _ := consensus.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil || !cached.CacheHit {
+		t.Errorf("Expected the second report to come from the cache, but got %+v (%v)", cached, err)
+	}
+
+	if merged.CacheHit || merged.ReportingService != data.ConsensusService {
+		t.Errorf("Expected the consensus report not to come from the cache, but got %+v", merged)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected the service to be called twice, but it was called %d times", calls)
+	}
+}
+
+func TestMemoryStore_Set_DropsLeastRecentlyUsed(t *testing.T) {
+	//	Arrange
+	store := &data.MemoryStore{Size: 2}
+	ctx := context.Background()
+
+	//	Act
+	store.Set(ctx, "a", data.CacheEntry{})
+	store.Set(ctx, "b", data.CacheEntry{})
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", data.CacheEntry{})
+
+	//	Assert
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Errorf("Expected the least recently used entry to be dropped")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := store.Get(ctx, key); !ok {
+			t.Errorf("Expected entry %s to still be cached", key)
+		}
+	}
+
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 entries, but got %d", store.Len())
+	}
+}
+
+func TestCache_Strategy_SharedStoreHitsAcrossContainers(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	var calls int32
+	store := &data.MemoryStore{}
+	service := countingService{name: "Counting", calls: &calls, report: data.PollenReport{Data: []float64{1, 2}}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Each container wraps the strategy the same way main does, with its own coalescer and archive
+	container := func() data.Strategy {
+		strategy := (&data.Coalescer{}).Strategy(data.Consensus{Method: data.MergeMean})
+		strategy = data.Cache{Store: store}.Strategy(strategy)
+		return (&data.Archive{Store: data.LocalReportStore{Dir: dir}}).Strategy(strategy)
+	}
+
+	//	Act
+	first, _ := container().Aggregate(ctx, []data.PollenService{service}, "30019")
+	second, _ := container().Aggregate(ctx, []data.PollenService{service}, "30019")
+
+	//	Assert
+	if first.CacheHit || !second.CacheHit || calls != 1 {
+		t.Errorf("Expected the second container to get the first one's cached report, but got %v, %v (%d calls)", first.CacheHit, second.CacheHit, calls)
+	}
+}
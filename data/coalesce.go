@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// DefaultCoalesceTimeout is how long a shared call can take when the request
+// that starts it doesn't have a deadline (and the coalescer doesn't set a timeout)
+const DefaultCoalesceTimeout = 10 * time.Second
+
+// Coalescer merges concurrent requests for the same zipcode, so a burst of
+// requests waits on a single set of service calls (and they all get the same
+// result).  Nothing is kept once the calls finish -- use a Cache for that.  The
+// zero value is ready to use
+type Coalescer struct {
+	Timeout time.Duration // How long a shared call can take when the request that starts it doesn't have a deadline (optional -- defaults to DefaultCoalesceTimeout)
+
+	mu       sync.Mutex
+	inflight map[string]*coalescedCall // The calls in progress, by strategy and zipcode
+}
+
+// coalescedCall is a report being fetched for one or more requests
+type coalescedCall struct {
+	done    chan struct{} // Closed when the report is fetched
+	report  PollenReport
+	err     error
+	waiters int // How many requests are sharing the call
+}
+
+// Strategy returns the strategy with concurrent requests for the same zipcode
+// merged.  Requests are merged separately for each strategy (and its settings)
+// and set of services
+func (c *Coalescer) Strategy(strategy Strategy) Strategy {
+	return coalescedStrategy{coalescer: c, strategy: strategy}
+}
+
+// coalescedStrategy is a strategy with concurrent requests merged
+type coalescedStrategy struct {
+	coalescer *Coalescer
+	strategy  Strategy
+}
+
+// Unwrap returns the strategy with the requests merged
+func (s coalescedStrategy) Unwrap() Strategy {
+	return s.strategy
+}
+
+// Aggregate gets the report with the strategy, or waits on the request that's
+// already getting it.  If the context is done first, every service is reported
+// as timed out (the same as if the strategy had been called directly)
+func (s coalescedStrategy) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	key := fmt.Sprintf("%s|%s", strategyNamespace(s.strategy, services), NormalizeZipcode(zipcode))
+	start := time.Now()
+
+	call := s.coalescer.join(ctx, key, func(ctx context.Context) (PollenReport, error) {
+		return s.strategy.Aggregate(ctx, services, zipcode)
+	})
+
+	select {
+	case <-call.done:
+		return call.report, call.err
+	case <-ctx.Done():
+		failures := make([]*ProviderError, len(services))
+		timeoutPending(failures, nil, services, start, ctx.Err())
+		return PollenReport{}, &MultiProviderError{Zipcode: zipcode, Errors: failures}
+	}
+}
+
+// join returns the call for the key, starting it (with fetch) if it isn't
+// already in progress.  The call gets its own context with the deadline of the
+// request that started it, so it isn't canceled if that request goes away --
+// but it doesn't outlast it either.  Each request only waits as long as its own
+// context allows
+func (c *Coalescer) join(ctx context.Context, key string, fetch func(context.Context) (PollenReport, error)) *coalescedCall {
+	c.mu.Lock()
+	if c.inflight == nil {
+		c.inflight = map[string]*coalescedCall{}
+	}
+
+	call, ok := c.inflight[key]
+	if ok {
+		call.waiters++
+	} else {
+		call = &coalescedCall{done: make(chan struct{}), waiters: 1}
+		c.inflight[key] = call
+
+		deadline, hasDeadline := ctx.Deadline()
+		if !hasDeadline {
+			deadline = time.Now().Add(c.timeout())
+		}
+		go c.call(xray.DetachContext(ctx), deadline, key, call, fetch)
+	}
+	c.mu.Unlock()
+
+	xray.AddAnnotation(ctx, "coalesced", ok)
+
+	return call
+}
+
+// call calls fetch for the key (by the deadline), and lets the requests
+// waiting on it know when it's done
+func (c *Coalescer) call(ctx context.Context, deadline time.Time, key string, call *coalescedCall, fetch func(context.Context) (PollenReport, error)) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	call.report, call.err = fetch(ctx)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.waiters > 1 {
+		xray.AddMetadata(ctx, "CoalescedRequests", call.waiters)
+	}
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+// timeout returns how long a shared call can take when the request that
+// starts it doesn't have a deadline
+func (c *Coalescer) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+
+	return DefaultCoalesceTimeout
+}
@@ -0,0 +1,196 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// aggregateConcurrently calls the strategy from n goroutines at once, and
+// returns each report and error
+func aggregateConcurrently(ctx context.Context, n int, strategy data.Strategy, services []data.PollenService, zipcode string) ([]data.PollenReport, []error) {
+	reports := make([]data.PollenReport, n)
+	errs := make([]error, n)
+
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			reports[i], errs[i] = strategy.Aggregate(ctx, services, zipcode)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	return reports, errs
+}
+
+func TestCoalescer_ConcurrentRequests_CallEachServiceOnce(t *testing.T) {
+	//	Arrange
+	var nasacortCalls, pollencomCalls int32
+	services := []data.PollenService{
+		countingService{name: "Nasacort", calls: &nasacortCalls, delay: 200 * time.Millisecond, report: data.PollenReport{Data: []float64{2, 4}}},
+		countingService{name: "Pollen.com", calls: &pollencomCalls, delay: 200 * time.Millisecond, report: data.PollenReport{Data: []float64{4, 6}}},
+	}
+	strategy := (&data.Coalescer{}).Strategy(data.Consensus{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	reports, errs := aggregateConcurrently(ctx, 25, strategy, services, "30019")
+
+	//	Assert
+	if nasacortCalls != 1 || pollencomCalls != 1 {
+		t.Errorf("Expected one call to each service, but got %d and %d", nasacortCalls, pollencomCalls)
+	}
+
+	for i := range reports {
+		if errs[i] != nil || reports[i].Data[0] != 3 {
+			t.Errorf("Expected every caller to get the merged report, but caller %d got %+v (%v)", i, reports[i], errs[i])
+		}
+	}
+}
+
+func TestCoalescer_ConcurrentRequests_ShareErrors(t *testing.T) {
+	//	Arrange
+	var calls int32
+	services := []data.PollenService{countingService{name: "Failing", calls: &calls, delay: 200 * time.Millisecond, err: errors.New("down")}}
+	strategy := (&data.Coalescer{}).Strategy(data.FirstResponse{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, errs := aggregateConcurrently(ctx, 10, strategy, services, "30019")
+
+	//	Assert
+	if calls != 1 {
+		t.Errorf("Expected one call to the service, but got %d", calls)
+	}
+
+	for i, err := range errs {
+		if _, ok := err.(*data.MultiProviderError); !ok {
+			t.Errorf("Expected caller %d to get a *data.MultiProviderError, but got %T (%v)", i, err, err)
+		}
+	}
+}
+
+func TestCoalescer_SequentialRequests_FetchEachTime(t *testing.T) {
+	//	Arrange
+	var calls int32
+	services := []data.PollenService{countingService{name: "Counting", calls: &calls, report: data.PollenReport{Data: []float64{1}}}}
+	strategy := (&data.Coalescer{}).Strategy(data.FirstResponse{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	strategy.Aggregate(ctx, services, "30019")
+	strategy.Aggregate(ctx, services, "30019")
+	strategy.Aggregate(ctx, services, "30092")
+
+	//	Assert
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected a call for each request that wasn't concurrent, but got %d", calls)
+	}
+}
+
+func TestCoalescer_WaiterContextDone_ReturnsTimeouts(t *testing.T) {
+	//	Arrange
+	var calls int32
+	services := []data.PollenService{countingService{name: "Slow", calls: &calls, delay: 300 * time.Millisecond, report: data.PollenReport{Data: []float64{1}}}}
+	strategy := (&data.Coalescer{}).Strategy(data.FirstResponse{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	go strategy.Aggregate(ctx, services, "30019")
+	time.Sleep(50 * time.Millisecond)
+
+	waiterCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	//	Act
+	_, err := strategy.Aggregate(waiterCtx, services, "30019")
+	made := atomic.LoadInt32(&calls)
+
+	//	Assert
+	merr, ok := err.(*data.MultiProviderError)
+	if !ok || len(merr.Errors) != 1 || merr.Errors[0].Service != "Slow" || merr.Errors[0].Reason != data.FailureTimeout {
+		t.Fatalf("Expected a timeout for the service, but got %T (%v)", err, err)
+	}
+
+	if made != 1 {
+		t.Errorf("Expected the waiter to give up without another call, but got %d calls", made)
+	}
+}
+
+func TestCoalescer_FirstRequestCanceled_WaitersGetReport(t *testing.T) {
+	//	Arrange
+	var calls int32
+	services := []data.PollenService{countingService{name: "Slow", calls: &calls, delay: 200 * time.Millisecond, report: data.PollenReport{Data: []float64{1, 2}}}}
+	strategy := (&data.Coalescer{}).Strategy(data.FirstResponse{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	firstCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := strategy.Aggregate(firstCtx, services, "30019")
+		firstErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	time.AfterFunc(30*time.Millisecond, cancel)
+
+	//	Act
+	report, err := strategy.Aggregate(ctx, services, "30019")
+	made := atomic.LoadInt32(&calls)
+
+	//	Assert
+	if _, ok := (<-firstErr).(*data.MultiProviderError); !ok {
+		t.Errorf("Expected the first request to give up")
+	}
+
+	if err != nil || len(report.Data) != 2 || made != 1 {
+		t.Errorf("Expected the waiter to get the shared report from a single call, but got %+v (%v) after %d calls", report, err, made)
+	}
+}
+
+func TestCoalescer_SharedCall_HasFirstRequestsDeadline(t *testing.T) {
+	//	Arrange
+	var calls int32
+	services := []data.PollenService{countingService{name: "Slow", calls: &calls, delay: 500 * time.Millisecond, report: data.PollenReport{Data: []float64{1, 2}}}}
+	strategy := (&data.Coalescer{}).Strategy(data.FirstResponse{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	firstCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	go strategy.Aggregate(firstCtx, services, "30019")
+	time.Sleep(20 * time.Millisecond)
+
+	//	Act
+	start := time.Now()
+	_, err := strategy.Aggregate(ctx, services, "30019")
+	waited := time.Since(start)
+
+	//	Assert
+	merr, ok := err.(*data.MultiProviderError)
+	if !ok || len(merr.Errors) != 1 || merr.Errors[0].Reason != data.FailureTimeout {
+		t.Errorf("Expected the shared call to time out, but got %T (%v)", err, err)
+	}
+
+	if waited >= 400*time.Millisecond {
+		t.Errorf("Expected the shared call to end at the first request's deadline, but waited %s", waited)
+	}
+}
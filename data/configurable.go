@@ -0,0 +1,534 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/jmespath/go-jmespath"
+)
+
+// ProviderConfig is the provider config file: the configurable pollen
+// services, and which providers to use
+type ProviderConfig struct {
+	Active    []string             `json:"active,omitempty"` // The registered names of the providers to use, in order (optional -- defaults to every provider that's available)
+	Providers []ProviderDefinition `json:"providers"`        // The definition for each configurable service
+}
+
+// ProviderDefinition defines a pollen service for a JSON feed: how to call it,
+// and JMESPath expressions that pull the report out of its response.  The url,
+// header, query and form values can use {zip} for the zipcode and {env:NAME}
+// for the NAME environment variable (for things like API keys)
+type ProviderDefinition struct {
+	Name     string            `json:"name"`              // The name of the service
+	URL      string            `json:"url"`               // The url template
+	Method   string            `json:"method,omitempty"`  // The HTTP method: GET or POST (optional -- defaults to GET)
+	Headers  map[string]string `json:"headers,omitempty"` // Header templates
+	Query    map[string]string `json:"query,omitempty"`   // Query parameter templates
+	Form     map[string]string `json:"form,omitempty"`    // Form parameter templates (for POST only)
+	Scale    *IndexScale       `json:"scale,omitempty"`   // The native scale of the indices (optional -- defaults to the canonical 0-12 scale)
+	Mappings ProviderMappings  `json:"mappings"`          // Where the report is in the response
+
+	ForecastDays int      `json:"forecast_days,omitempty"` // How many days of forecast the feed has, for the provider registry (optional)
+	Countries    []string `json:"countries,omitempty"`     // The ISO country codes the feed covers, for the provider registry (optional)
+	Priority     int      `json:"priority,omitempty"`      // The hedged strategy's priority tier for the feed, for the provider registry (optional)
+}
+
+// ProviderMappings are the JMESPath expressions that pull a report out of a
+// service response
+type ProviderMappings struct {
+	Indices   string `json:"indices"`             // The index for each day, starting with today (a list of numbers)
+	Dates     string `json:"dates,omitempty"`     // The YYYY-MM-DD date for each index (optional -- defaults to consecutive days starting today)
+	Location  string `json:"location,omitempty"`  // The city/state location (optional)
+	Allergens string `json:"allergens,omitempty"` // The predominant allergens: a list of names, or free text like "Oak, Birch and Pine" (optional)
+}
+
+// ConfigurablePollenService is a pollen service built from a ProviderDefinition
+type ConfigurablePollenService struct {
+	Client *http.Client // The HTTP client to use (optional -- defaults to an X-Ray instrumented client)
+
+	definition ProviderDefinition
+	indices    *jmespath.JMESPath
+	dates      *jmespath.JMESPath
+	location   *jmespath.JMESPath
+	allergens  *jmespath.JMESPath
+}
+
+// templatePlaceholder matches a {placeholder} in a template
+var templatePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// envPlaceholder matches an {env:NAME} placeholder
+var envPlaceholder = regexp.MustCompile(`^\{env:([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// LoadProviderConfig reads the provider config file and returns a service for
+// each definition (see ProviderConfig.Services)
+func LoadProviderConfig(path string) ([]*ConfigurablePollenService, error) {
+	config, err := ReadProviderConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := config.Services()
+	if err != nil {
+		return nil, fmt.Errorf("The provider config %s is invalid: %s", path, err)
+	}
+
+	return services, nil
+}
+
+// ReadProviderConfig reads the provider config file (without validating it)
+func ReadProviderConfig(path string) (ProviderConfig, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ProviderConfig{}, fmt.Errorf("There was a problem reading the provider config: %s", err)
+	}
+
+	config := ProviderConfig{}
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return ProviderConfig{}, fmt.Errorf("There was a problem decoding the provider config %s: %s", path, err)
+	}
+
+	return config, nil
+}
+
+// Services returns a service for each definition in the config.  Every
+// definition is validated -- if any are invalid, the error describes all of
+// the problems
+func (c ProviderConfig) Services() ([]*ConfigurablePollenService, error) {
+	services := []*ConfigurablePollenService{}
+	problems := []string{}
+	names := map[string]bool{}
+	for i, definition := range c.Providers {
+		service, err := NewConfigurablePollenService(definition)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("provider %d: %s", i+1, err))
+			continue
+		}
+
+		if names[strings.ToLower(definition.Name)] {
+			problems = append(problems, fmt.Sprintf("provider %d: '%s' is defined more than once", i+1, definition.Name))
+			continue
+		}
+		names[strings.ToLower(definition.Name)] = true
+
+		services = append(services, service)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return services, nil
+}
+
+// NewConfigurablePollenService validates the definition and returns the service for it
+func NewConfigurablePollenService(definition ProviderDefinition) (*ConfigurablePollenService, error) {
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+
+	service := &ConfigurablePollenService{definition: definition}
+	service.indices, _ = jmespath.Compile(definition.Mappings.Indices)
+	service.dates = compileOptional(definition.Mappings.Dates)
+	service.location = compileOptional(definition.Mappings.Location)
+	service.allergens = compileOptional(definition.Mappings.Allergens)
+
+	return service, nil
+}
+
+// Validate returns an error describing everything wrong with the definition
+func (d ProviderDefinition) Validate() error {
+	problems := []string{}
+
+	if strings.TrimSpace(d.Name) == "" {
+		problems = append(problems, "a name is required")
+	}
+
+	if d.URL == "" {
+		problems = append(problems, "a url is required")
+	} else if parsed, err := url.Parse(expandTemplate(d.URL, "00000", url.PathEscape)); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		problems = append(problems, fmt.Sprintf("the url '%s' isn't an absolute http(s) url", d.URL))
+	}
+
+	switch strings.ToUpper(d.Method) {
+	case "", http.MethodGet:
+		if len(d.Form) > 0 {
+			problems = append(problems, "form parameters can only be sent with POST")
+		}
+	case http.MethodPost:
+	default:
+		problems = append(problems, fmt.Sprintf("the method '%s' isn't GET or POST", d.Method))
+	}
+
+	//	Check the placeholders (and that any environment variables they use are set)
+	templates := []string{d.URL}
+	for _, values := range []map[string]string{d.Headers, d.Query, d.Form} {
+		for _, value := range values {
+			templates = append(templates, value)
+		}
+	}
+	for _, template := range templates {
+		for _, placeholder := range templatePlaceholder.FindAllString(template, -1) {
+			if placeholder == "{zip}" {
+				continue
+			}
+
+			match := envPlaceholder.FindStringSubmatch(placeholder)
+			if match == nil {
+				problems = append(problems, fmt.Sprintf("unknown placeholder %s", placeholder))
+			} else if os.Getenv(match[1]) == "" {
+				problems = append(problems, fmt.Sprintf("the environment variable %s isn't set", match[1]))
+			}
+		}
+	}
+
+	if d.Scale != nil {
+		if d.Scale.Max <= d.Scale.Min {
+			problems = append(problems, "the scale max must be more than its min")
+		}
+		if len(d.Scale.Thresholds) != 0 && len(d.Scale.Thresholds) != len(categories)-1 {
+			problems = append(problems, fmt.Sprintf("the scale needs %d thresholds (or none)", len(categories)-1))
+		}
+		for i, threshold := range d.Scale.Thresholds {
+			if threshold <= d.Scale.Min || threshold >= d.Scale.Max || (i > 0 && threshold <= d.Scale.Thresholds[i-1]) {
+				problems = append(problems, "the scale thresholds must be increasing, and between its min and max")
+				break
+			}
+		}
+	}
+
+	//	The indices are required -- everything else is optional
+	if d.Mappings.Indices == "" {
+		problems = append(problems, "an indices expression is required")
+	}
+	for _, mapping := range []struct{ name, expression string }{
+		{"indices", d.Mappings.Indices},
+		{"dates", d.Mappings.Dates},
+		{"location", d.Mappings.Location},
+		{"allergens", d.Mappings.Allergens},
+	} {
+		if mapping.expression == "" {
+			continue
+		}
+		if _, err := jmespath.Compile(mapping.expression); err != nil {
+			problems = append(problems, fmt.Sprintf("the %s expression '%s' is invalid: %s", mapping.name, mapping.expression, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		name := d.Name
+		if strings.TrimSpace(name) == "" {
+			name = "(unnamed)"
+		}
+		return fmt.Errorf("%s: %s", name, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// Name returns the name of the service
+func (s *ConfigurablePollenService) Name() string {
+	return s.definition.Name
+}
+
+// Info returns the service's capabilities, for registering it as a provider
+func (s *ConfigurablePollenService) Info() ProviderInfo {
+	return ProviderInfo{
+		Name:         s.definition.Name,
+		ForecastDays: s.definition.ForecastDays,
+		Countries:    s.definition.Countries,
+		Priority:     s.definition.Priority,
+	}
+}
+
+// Scale returns the native scale for the service
+func (s *ConfigurablePollenService) Scale() IndexScale {
+	if s.definition.Scale != nil {
+		scale := *s.definition.Scale
+		if scale.Name == "" {
+			scale.Name = s.definition.Name
+		}
+		return scale
+	}
+
+	return CanonicalScale
+}
+
+// GetPollenReport gets the pollen report
+func (s *ConfigurablePollenService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "configurable-service")
+	xray.AddAnnotation(ctx, "provider", s.Name())
+
+	payload, err := s.fetch(ctx, zipcode)
+	if err != nil {
+		seg.AddError(err)
+		return PollenReport{}, err
+	}
+
+	retval, err := s.Extract(zipcode, payload.Body)
+	if err != nil {
+		seg.AddError(err)
+		return PollenReport{}, err
+	}
+
+	xray.AddMetadata(ctx, "ConfigurableResult", retval)
+
+	// Close the segment
+	seg.Close(nil)
+
+	return retval, nil
+}
+
+// GetRawReport gets the response the pollen report is built from
+func (s *ConfigurablePollenService) GetRawReport(ctx context.Context, zipcode string) ([]RawPayload, error) {
+	payload, err := s.fetch(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return []RawPayload{payload}, nil
+}
+
+// Extract builds the pollen report for the zipcode from a response body, using
+// the definition's mappings.  It doesn't call the service, so it can be used to
+// try a definition out on a sample payload
+func (s *ConfigurablePollenService) Extract(zipcode string, body []byte) (PollenReport, error) {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return PollenReport{}, s.decodeError(fmt.Errorf("There was a problem decoding the response from %s: %s", s.Name(), err))
+	}
+
+	//	Parse the data items:
+	result, err := s.indices.Search(document)
+	if err != nil {
+		return PollenReport{}, s.decodeError(fmt.Errorf("There was a problem searching for the indices: %s", err))
+	}
+
+	values, ok := result.([]interface{})
+	if result != nil && !ok {
+		return PollenReport{}, s.decodeError(fmt.Errorf("The indices should be a list, but they're %s", describeJSON(result)))
+	}
+
+	dataitems := []float64{}
+	for i, value := range values {
+		index, ok := parseNumber(value)
+		if !ok {
+			return PollenReport{}, s.decodeError(fmt.Errorf("Index %d should be a number, but it's %s", i+1, describeJSON(value)))
+		}
+		dataitems = append(dataitems, index)
+	}
+
+	if len(dataitems) == 0 {
+		return PollenReport{}, &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureInsufficientData,
+			Err:     fmt.Errorf("%s didn't have any pollen indices for %s", s.Name(), zipcode),
+		}
+	}
+
+	//	Date the days (starting today in the zipcode's timezone, if there aren't dates)
+	scale := s.Scale()
+	days := newForecastDays(time.Now().In(zipcodeLocation(zipcode)), dataitems, scale)
+	if s.dates != nil {
+		dates, err := s.searchStrings(s.dates, document)
+		if err != nil {
+			return PollenReport{}, s.decodeError(fmt.Errorf("There was a problem with the dates: %s", err))
+		}
+		if len(dates) != len(dataitems) {
+			return PollenReport{}, s.decodeError(fmt.Errorf("There are %d dates for %d indices", len(dates), len(dataitems)))
+		}
+
+		for i, date := range dates {
+			if _, err := time.Parse(DateFormat, date); err != nil {
+				return PollenReport{}, s.decodeError(fmt.Errorf("The date '%s' isn't formatted as YYYY-MM-DD", date))
+			}
+			days[i] = newForecastDay(date, dataitems[i], scale)
+		}
+	}
+
+	location := ""
+	if s.location != nil {
+		result, err := s.location.Search(document)
+		if err != nil {
+			return PollenReport{}, s.decodeError(fmt.Errorf("There was a problem searching for the location: %s", err))
+		}
+		location, _ = result.(string)
+	}
+
+	allergens := []Allergen{}
+	if s.allergens != nil {
+		result, err := s.allergens.Search(document)
+		if err != nil {
+			return PollenReport{}, s.decodeError(fmt.Errorf("There was a problem searching for the allergens: %s", err))
+		}
+
+		switch names := result.(type) {
+		case string:
+			allergens = ParseAllergens(names)
+		case []interface{}:
+			for _, name := range names {
+				if name, ok := name.(string); ok && strings.TrimSpace(name) != "" {
+					allergens = append(allergens, lookupPlural(strings.TrimSpace(name)))
+				}
+			}
+			allergens = mergeAllergens(allergens)
+		}
+	}
+
+	predomPollens := []string{}
+	for _, allergen := range allergens {
+		predomPollens = append(predomPollens, allergen.Name)
+	}
+
+	//	Set the properties in the return object:
+	return PollenReport{
+		ReportingService:  s.Name(),
+		PredominantPollen: strings.Join(predomPollens, ", "),
+		Zipcode:           zipcode,
+		Location:          location,
+		StartDate:         time.Now(),
+		Data:              dataitems,
+		Days:              days,
+		Allergens:         allergens,
+	}, nil
+}
+
+// fetch calls the service for the zipcode
+func (s *ConfigurablePollenService) fetch(ctx context.Context, zipcode string) (RawPayload, error) {
+	zipcode = NormalizeZipcode(zipcode)
+	definition := s.definition
+
+	apiurl, err := url.Parse(expandTemplate(definition.URL, zipcode, url.PathEscape))
+	if err != nil {
+		return RawPayload{}, &ProviderError{Service: s.Name(), Reason: FailureConfig, Err: err}
+	}
+
+	query := apiurl.Query()
+	for name, value := range definition.Query {
+		query.Set(name, expandTemplate(value, zipcode, nil))
+	}
+	apiurl.RawQuery = query.Encode()
+
+	method := strings.ToUpper(definition.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var req *http.Request
+	if len(definition.Form) > 0 {
+		form := url.Values{}
+		for name, value := range definition.Form {
+			form.Set(name, expandTemplate(value, zipcode, nil))
+		}
+		req, _ = http.NewRequest(method, apiurl.String(), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, _ = http.NewRequest(method, apiurl.String(), nil)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for name, value := range definition.Headers {
+		req.Header.Set(name, expandTemplate(value, zipcode, nil))
+	}
+
+	return doPayload(ctx, s.Client, s.Name(), s.Name(), req, nil)
+}
+
+// searchStrings runs the expression and returns the list of strings it finds
+func (s *ConfigurablePollenService) searchStrings(expression *jmespath.JMESPath, document interface{}) ([]string, error) {
+	result, err := expression.Search(document)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := result.([]interface{})
+	if result != nil && !ok {
+		return nil, fmt.Errorf("expected a list, but got %s", describeJSON(result))
+	}
+
+	retval := []string{}
+	for _, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, but got %s", describeJSON(value))
+		}
+		retval = append(retval, text)
+	}
+
+	return retval, nil
+}
+
+// decodeError returns a decode failure for the service
+func (s *ConfigurablePollenService) decodeError(err error) *ProviderError {
+	return &ProviderError{Service: s.Name(), Reason: FailureDecode, Err: err}
+}
+
+// expandTemplate fills in the {zip} and {env:NAME} placeholders in the
+// template.  If escape is passed, the values are escaped with it
+func expandTemplate(template, zipcode string, escape func(string) string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value := placeholder
+		if placeholder == "{zip}" {
+			value = zipcode
+		} else if match := envPlaceholder.FindStringSubmatch(placeholder); match != nil {
+			value = os.Getenv(match[1])
+		} else {
+			return placeholder
+		}
+
+		if escape != nil {
+			return escape(value)
+		}
+		return value
+	})
+}
+
+// compileOptional compiles an expression that's already been validated, or
+// returns nil if there isn't one
+func compileOptional(expression string) *jmespath.JMESPath {
+	if expression == "" {
+		return nil
+	}
+
+	compiled, _ := jmespath.Compile(expression)
+	return compiled
+}
+
+// parseNumber returns the number for a JSON value (a number, or a string with
+// a number in it)
+func parseNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		return parsed, err == nil && !math.IsNaN(parsed) && !math.IsInf(parsed, 0)
+	}
+
+	return 0, false
+}
+
+// describeJSON describes a JSON value for an error message
+func describeJSON(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "an unknown value"
+	}
+
+	return string(encoded)
+}
@@ -0,0 +1,180 @@
+package data_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+const configurableTestToken = "regional-test-token"
+
+// newRegionalServer starts a stand-in for the regional feed in the provider
+// config fixture.  It only answers requests that are made the way the
+// definition says to make them.  Callers should Close() it
+func newRegionalServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	body := loadFixture(t, "configurable/regional_30019.json")
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+
+		switch {
+		case req.URL.Path != "/v2/stations/30019/outlook":
+			http.NotFound(rw, req)
+		case req.Method != http.MethodPost || req.PostForm.Get("zip") != "30019" || req.PostForm.Get("days") != "3" || req.URL.Query().Get("units") != "index":
+			rw.WriteHeader(http.StatusBadRequest)
+		case req.Header.Get("Authorization") != "Bearer "+configurableTestToken:
+			rw.WriteHeader(http.StatusUnauthorized)
+		default:
+			rw.Write(body)
+		}
+	}))
+}
+
+// loadRegionalProvider loads the provider config fixture, pointed at the url
+func loadRegionalProvider(t *testing.T, serverURL string) *data.ConfigurablePollenService {
+	t.Helper()
+
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	config := strings.Replace(string(loadFixture(t, "configurable/providers.json")), "https://pollen.example.com", serverURL, -1)
+	path := filepath.Join(dir, "providers.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("Unable to write the provider config: %v", err)
+	}
+
+	services, err := data.LoadProviderConfig(path)
+	if err != nil || len(services) != 1 {
+		t.Fatalf("Expected the provider config to load, but got %v (%v)", services, err)
+	}
+
+	return services[0]
+}
+
+func TestConfigurablePollenService_GetPollenReport_ReturnsValidData(t *testing.T) {
+	//	Arrange
+	os.Setenv("REGIONAL_POLLEN_TOKEN", configurableTestToken)
+	defer os.Unsetenv("REGIONAL_POLLEN_TOKEN")
+
+	server := newRegionalServer(t)
+	defer server.Close()
+
+	service := loadRegionalProvider(t, server.URL)
+	service.Client = server.Client()
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	if response.ReportingService != "Regional Pollen" || response.Location != "Dacula, GA" {
+		t.Errorf("Expected a report for Dacula, GA from Regional Pollen, but got %s from %s", response.Location, response.ReportingService)
+	}
+
+	//	Numbers in strings are numbers too
+	if expected := []float64{9.6, 10.2, 7.1}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected data %v, but got %v", expected, response.Data)
+	}
+
+	if len(response.Days) != 3 || response.Days[1].Date != "2019-04-19" || response.Days[1].Category != data.CategoryHigh {
+		t.Errorf("Expected the dated days from the feed, but got %+v", response.Days)
+	}
+
+	if response.PredominantPollen != "Oak, Birch, Grass" || response.Allergens[0].Genus != "Quercus" {
+		t.Errorf("Expected the catalog allergens for today, but got '%s' (%+v)", response.PredominantPollen, response.Allergens)
+	}
+}
+
+func TestLoadProviderConfig_InvalidDefinitions_ReturnsEveryProblem(t *testing.T) {
+	//	Arrange
+	dir, cleanup := newTestArchiveDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "providers.json")
+	ioutil.WriteFile(path, []byte(`{"providers": [
+		{"name": "Broken", "url": "ftp://pollen.example.com/{zip}?key={env:POLLEN_UNSET_TEST_KEY}", "method": "PUT", "mappings": {"indices": "outlook[].["}},
+		{"name": "Fine", "url": "https://pollen.example.com/{zip}", "mappings": {"indices": "days[].index"}},
+		{"name": "fine", "url": "https://pollen.example.com/{zipcode}", "form": {"zip": "{zip}"}, "scale": {"min": 0, "max": 5, "thresholds": [1, 2]}, "mappings": {}}
+	]}`), 0644)
+
+	//	Act
+	services, err := data.LoadProviderConfig(path)
+
+	//	Assert
+	if err == nil || services != nil {
+		t.Fatalf("Expected the invalid config to be rejected, but got %v", services)
+	}
+
+	for _, problem := range []string{
+		"provider 1: Broken:",
+		"isn't an absolute http(s) url",
+		"the method 'PUT' isn't GET or POST",
+		"the environment variable POLLEN_UNSET_TEST_KEY isn't set",
+		"the indices expression 'outlook[].[' is invalid",
+		"provider 3: fine:",
+		"unknown placeholder {zipcode}",
+		"form parameters can only be sent with POST",
+		"the scale needs 4 thresholds",
+		"an indices expression is required",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected the error to include '%s', but got: %v", problem, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "provider 2") {
+		t.Errorf("Expected the valid definition to pass, but got: %v", err)
+	}
+}
+
+func TestConfigurablePollenService_Extract_ReportsBadPayloads(t *testing.T) {
+	//	Arrange
+	service, err := data.NewConfigurablePollenService(data.ProviderDefinition{
+		Name:     "Regional Pollen",
+		URL:      "https://pollen.example.com/{zip}",
+		Mappings: data.ProviderMappings{Indices: "outlook[].level", Dates: "outlook[].day"},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create the service: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		reason  data.FailureReason
+	}{
+		{"not JSON", `<html>`, data.FailureDecode},
+		{"no indices", `{"outlook": []}`, data.FailureInsufficientData},
+		{"missing feed", `{"status": "ok"}`, data.FailureInsufficientData},
+		{"index isn't a number", `{"outlook": [{"day": "2019-04-18", "level": "high"}]}`, data.FailureDecode},
+		{"indices aren't a list", `{"outlook": {"level": 4}}`, data.FailureInsufficientData},
+		{"missing date", `{"outlook": [{"day": "2019-04-18", "level": 4}, {"level": 5}]}`, data.FailureDecode},
+		{"bad date", `{"outlook": [{"day": "04/18/2019", "level": 4}]}`, data.FailureDecode},
+	}
+
+	for _, test := range tests {
+		//	Act
+		_, err := service.Extract("30019", []byte(test.payload))
+
+		//	Assert
+		if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != test.reason || perr.Service != "Regional Pollen" {
+			t.Errorf("%s: expected a %s error, but got %T (%v)", test.name, test.reason, err, err)
+		}
+	}
+}
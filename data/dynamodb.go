@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// DefaultDynamoDBTable is the table cached reports are kept in when the store doesn't set one
+const DefaultDynamoDBTable = "pollen-cache"
+
+// DefaultDynamoDBRetention is how long DynamoDB keeps an entry after it expires
+// (before the table's TTL deletes it) when the store doesn't set a retention
+const DefaultDynamoDBRetention = 24 * time.Hour
+
+// DynamoDB item attribute names.  The table's partition key is dynamoKeyAttribute
+// (a string), and its TTL attribute is dynamoTTLAttribute
+const (
+	dynamoKeyAttribute     = "cache_key"
+	dynamoEntryAttribute   = "entry"
+	dynamoFetchedAttribute = "fetched_at"
+	dynamoTTLAttribute     = "expires"
+)
+
+// DynamoDBStore keeps cached reports in a DynamoDB table, so every Lambda
+// container shares them.  Writes are conditional: an entry is only replaced by
+// one that was fetched later, so containers racing to cache the same zipcode
+// don't overwrite each other's newer reports
+type DynamoDBStore struct {
+	Table     string        // The table name
+	Retention time.Duration // How long DynamoDB keeps an entry after it expires (optional -- defaults to DefaultDynamoDBRetention)
+
+	client *dynamodb.DynamoDB
+}
+
+// NewDynamoDBStore returns a store for the table (DefaultDynamoDBTable if it's
+// blank).  The configs can set the region, credentials and endpoint (like
+// http://localhost:8000 for DynamoDB Local)
+func NewDynamoDBStore(table string, configs ...*aws.Config) (*DynamoDBStore, error) {
+	if table == "" {
+		table = DefaultDynamoDBTable
+	}
+
+	sess, err := session.NewSession(configs...)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem creating the DynamoDB session: %v", err)
+	}
+
+	//	DynamoDB Local doesn't care about the region, but the signer does
+	config := aws.NewConfig()
+	if aws.StringValue(sess.Config.Region) == "" {
+		config.Region = aws.String("us-east-1")
+	}
+
+	client := dynamodb.New(sess, config)
+	xray.AWS(client.Client)
+
+	return &DynamoDBStore{Table: table, client: client}, nil
+}
+
+// Get gets the entry for the key
+func (s *DynamoDBStore) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	output, err := s.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.Table),
+		Key: map[string]*dynamodb.AttributeValue{
+			dynamoKeyAttribute: {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		return CacheEntry{}, false, fmt.Errorf("There was a problem getting '%s' from DynamoDB: %v", key, err)
+	}
+
+	attribute, ok := output.Item[dynamoEntryAttribute]
+	if !ok || attribute.S == nil {
+		return CacheEntry{}, false, nil
+	}
+
+	entry := CacheEntry{}
+	if err := json.Unmarshal([]byte(aws.StringValue(attribute.S)), &entry); err != nil {
+		return CacheEntry{}, false, fmt.Errorf("There was a problem decoding '%s' from DynamoDB: %v", key, err)
+	}
+
+	return entry, true, nil
+}
+
+// Set stores the entry for the key, unless the table already has an entry that
+// was fetched at the same time or later
+func (s *DynamoDBStore) Set(ctx context.Context, key string, entry CacheEntry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding '%s' for DynamoDB: %v", key, err)
+	}
+
+	retention := s.Retention
+	if retention <= 0 {
+		retention = DefaultDynamoDBRetention
+	}
+
+	fetched := aws.String(strconv.FormatInt(entry.FetchedAt.UnixNano(), 10))
+	_, err = s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			dynamoKeyAttribute:     {S: aws.String(key)},
+			dynamoEntryAttribute:   {S: aws.String(string(encoded))},
+			dynamoFetchedAttribute: {N: fetched},
+			dynamoTTLAttribute:     {N: aws.String(strconv.FormatInt(entry.ExpiresAt.Add(retention).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #fetched < :fetched"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":     aws.String(dynamoKeyAttribute),
+			"#fetched": aws.String(dynamoFetchedAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fetched": {N: fetched},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		//	Another container already cached a newer report
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("There was a problem putting '%s' in DynamoDB: %v", key, err)
+	}
+
+	return nil
+}
+
+// CreateTable creates the table (with its TTL turned on).  It's handy for
+// DynamoDB Local -- in AWS, the table is usually created with the stack
+func (s *DynamoDBStore) CreateTable(ctx context.Context) error {
+	_, err := s.client.CreateTableWithContext(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(s.Table),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String(dynamoKeyAttribute), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String(dynamoKeyAttribute), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	if err != nil {
+		return fmt.Errorf("There was a problem creating the DynamoDB table '%s': %v", s.Table, err)
+	}
+
+	_, err = s.client.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(s.Table),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(dynamoTTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("There was a problem turning on TTL for the DynamoDB table '%s': %v", s.Table, err)
+	}
+
+	return nil
+}
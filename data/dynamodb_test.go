@@ -0,0 +1,204 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// fakeDynamoDB is just enough of the DynamoDB API (GetItem and PutItem, with
+// the cache's condition) to test the store without DynamoDB Local
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]map[string]string
+}
+
+func (f *fakeDynamoDB) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	input := struct {
+		TableName                 string
+		Key                       map[string]map[string]string
+		Item                      map[string]map[string]string
+		ConditionExpression       string
+		ExpressionAttributeValues map[string]map[string]string
+	}{}
+	json.NewDecoder(req.Body).Decode(&input)
+
+	if input.TableName != "pollen-test" || !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(rw, `{"__type":"com.amazon.coral.validate#ValidationException","message":"bad request"}`)
+		return
+	}
+
+	switch req.Header.Get("X-Amz-Target") {
+	case "DynamoDB_20120810.GetItem":
+		item, ok := f.items[input.Key["cache_key"]["S"]]
+		if !ok {
+			fmt.Fprint(rw, `{}`)
+			return
+		}
+		json.NewEncoder(rw).Encode(map[string]interface{}{"Item": item})
+
+	case "DynamoDB_20120810.PutItem":
+		key := input.Item["cache_key"]["S"]
+		if existing, ok := f.items[key]; ok && input.ConditionExpression != "" {
+			stored, _ := strconv.ParseInt(existing["fetched_at"]["N"], 10, 64)
+			fetched, _ := strconv.ParseInt(input.ExpressionAttributeValues[":fetched"]["N"], 10, 64)
+			if stored >= fetched {
+				rw.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(rw, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+				return
+			}
+		}
+		f.items[key] = input.Item
+		fmt.Fprint(rw, `{}`)
+
+	default:
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(rw, `{"__type":"com.amazon.coral.service#UnknownOperationException"}`)
+	}
+}
+
+// newTestDynamoDBStore returns a store for the endpoint with fake credentials
+func newTestDynamoDBStore(t *testing.T, table, endpoint string) *data.DynamoDBStore {
+	t.Helper()
+
+	store, err := data.NewDynamoDBStore(table, &aws.Config{
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("local", "local", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	if err != nil {
+		t.Fatalf("Unable to create the store: %v", err)
+	}
+
+	return store
+}
+
+func TestDynamoDBStore_SetAndGet_KeepsNewestEntry(t *testing.T) {
+	//	Arrange
+	server := httptest.NewServer(&fakeDynamoDB{items: map[string]map[string]map[string]string{}})
+	defer server.Close()
+
+	store := newTestDynamoDBStore(t, "pollen-test", server.URL)
+	fetched := time.Date(2019, 4, 18, 16, 0, 0, 0, time.UTC)
+	newer := data.CacheEntry{Report: data.PollenReport{Zipcode: "30019", Data: []float64{9.1}}, FetchedAt: fetched, ExpiresAt: fetched.Add(time.Hour)}
+	older := data.CacheEntry{Report: data.PollenReport{Zipcode: "30019", Data: []float64{1}}, FetchedAt: fetched.Add(-time.Minute)}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, missing, missingErr := store.Get(ctx, "30019")
+	newerErr := store.Set(ctx, "30019", newer)
+	olderErr := store.Set(ctx, "30019", older)
+	entry, ok, err := store.Get(ctx, "30019")
+
+	//	Assert
+	if missing || missingErr != nil {
+		t.Errorf("Expected no entry before it's set, but got %v (%v)", missing, missingErr)
+	}
+
+	if newerErr != nil || olderErr != nil {
+		t.Errorf("Expected both sets to succeed (the older one does nothing), but got %v and %v", newerErr, olderErr)
+	}
+
+	if err != nil || !ok {
+		t.Fatalf("Expected the entry, but got %v (%v)", ok, err)
+	}
+
+	if entry.Report.Data[0] != 9.1 || !entry.FetchedAt.Equal(fetched) || !entry.ExpiresAt.Equal(newer.ExpiresAt) {
+		t.Errorf("Expected the newer entry, but got %+v", entry)
+	}
+}
+
+func TestDynamoDBStore_Get_ReturnsServiceErrors(t *testing.T) {
+	//	Arrange
+	server := httptest.NewServer(&fakeDynamoDB{items: map[string]map[string]map[string]string{}})
+	defer server.Close()
+
+	store := newTestDynamoDBStore(t, "missing-table", server.URL)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, ok, err := store.Get(ctx, "30019")
+
+	//	Assert
+	if err == nil || ok {
+		t.Errorf("Expected an error, but got %v (%v)", ok, err)
+	}
+}
+
+func TestDynamoDBStore_Cache_SharesReportsAcrossCaches(t *testing.T) {
+	//	Arrange
+	server := httptest.NewServer(&fakeDynamoDB{items: map[string]map[string]map[string]string{}})
+	defer server.Close()
+
+	var calls int32
+	service := countingService{name: "Counting", calls: &calls, report: data.PollenReport{Data: []float64{1, 2}}}
+
+	//	Two containers, each with their own cache in front of the same table
+	first := data.Cache{Store: newTestDynamoDBStore(t, "pollen-test", server.URL)}.Service(service)
+	second := data.Cache{Store: newTestDynamoDBStore(t, "pollen-test", server.URL)}.Service(service)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	first.GetPollenReport(ctx, "30019")
+	report, err := second.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err != nil || !report.CacheHit || calls != 1 {
+		t.Errorf("Expected the second container to use the first one's report, but got %+v (%v) after %d calls", report, err, calls)
+	}
+}
+
+func TestDynamoDBStore_DynamoDBLocal(t *testing.T) {
+	//	This needs DynamoDB Local (like 'docker run -p 8000:8000 amazon/dynamodb-local')
+	endpoint := os.Getenv("POLLEN_DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("Set POLLEN_DYNAMODB_ENDPOINT to test against DynamoDB Local")
+	}
+
+	//	Arrange
+	store := newTestDynamoDBStore(t, fmt.Sprintf("pollen-test-%d", time.Now().UnixNano()), endpoint)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	if err := store.CreateTable(ctx); err != nil {
+		t.Fatalf("Unable to create the table: %v", err)
+	}
+
+	fetched := time.Now().UTC()
+	entry := data.CacheEntry{Report: data.PollenReport{Zipcode: "30019", Data: []float64{9.1}}, FetchedAt: fetched, ExpiresAt: fetched.Add(time.Hour)}
+
+	//	Act
+	setErr := store.Set(ctx, "30019", entry)
+	staleErr := store.Set(ctx, "30019", data.CacheEntry{FetchedAt: fetched.Add(-time.Hour)})
+	got, ok, err := store.Get(ctx, "30019")
+
+	//	Assert
+	if setErr != nil || staleErr != nil {
+		t.Fatalf("Expected the sets to succeed, but got %v and %v", setErr, staleErr)
+	}
+
+	if err != nil || !ok || got.Report.Zipcode != "30019" {
+		t.Errorf("Expected the entry, but got %+v, %v (%v)", got, ok, err)
+	}
+}
@@ -0,0 +1,106 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FailureReason describes why a pollen service couldn't produce a report
+type FailureReason string
+
+const (
+	// FailureTransport means the upstream API couldn't be reached
+	FailureTransport FailureReason = "transport"
+
+	// FailureHTTPStatus means the upstream API answered with an error status code
+	FailureHTTPStatus FailureReason = "http_status"
+
+	// FailureDecode means the upstream response couldn't be decoded
+	FailureDecode FailureReason = "decode"
+
+	// FailureInsufficientData means the service answered, but without enough datapoints
+	FailureInsufficientData FailureReason = "insufficient_data"
+
+	// FailureTimeout means the caller's deadline passed before the service answered
+	FailureTimeout FailureReason = "timeout"
+
+	// FailureCircuitOpen means the service was skipped because it kept failing
+	FailureCircuitOpen FailureReason = "circuit_open"
+
+	// FailureQuota means the service's API quota (or rate limit) ran out
+	FailureQuota FailureReason = "quota"
+
+	// FailureConfig means the service isn't configured properly (like a missing API key)
+	FailureConfig FailureReason = "config"
+
+	// FailurePanic means the service panicked.  The panic is recovered, so the
+	// other services can still answer
+	FailurePanic FailureReason = "panic"
+
+	// FailureUnknown is used for errors that don't carry a more specific reason
+	FailureUnknown FailureReason = "unknown"
+)
+
+// ProviderError is the error for a single pollen service that failed to produce a report
+type ProviderError struct {
+	Service    string        `json:"service"`               // The reporting service
+	Reason     FailureReason `json:"reason"`                // Why the service failed
+	StatusCode int           `json:"status_code,omitempty"` // The upstream HTTP status code (if there was one)
+	Latency    time.Duration `json:"latency"`               // How long we waited on the service
+	Err        error         `json:"-"`                     // The underlying error
+}
+
+// Error returns the error message for the provider failure
+func (e *ProviderError) Error() string {
+	detail := string(e.Reason)
+	if e.StatusCode != 0 {
+		detail = fmt.Sprintf("%s %d", detail, e.StatusCode)
+	}
+
+	if e.Latency != 0 {
+		detail = fmt.Sprintf("%s after %s", detail, e.Latency)
+	}
+
+	msg := fmt.Sprintf("%s (%s)", e.Service, detail)
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+
+	return msg
+}
+
+// MultiProviderError is returned when none of the pollen services could produce a report
+type MultiProviderError struct {
+	Zipcode string           `json:"zip"`    // The zipcode for the request
+	Errors  []*ProviderError `json:"errors"` // The failure for each service, in the order they were passed
+}
+
+// Error returns the error message with the failure for each service
+func (e *MultiProviderError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("Unable to get a pollen report for %s: no pollen services were available", e.Zipcode)
+	}
+
+	failures := []string{}
+	for _, perr := range e.Errors {
+		failures = append(failures, perr.Error())
+	}
+
+	return fmt.Sprintf("Unable to get a pollen report for %s: %s", e.Zipcode, strings.Join(failures, "; "))
+}
+
+// newProviderError wraps an error returned by a service as a ProviderError
+func newProviderError(service string, err error, latency time.Duration) *ProviderError {
+	perr, ok := err.(*ProviderError)
+	if !ok {
+		perr = &ProviderError{Reason: FailureUnknown, Err: err}
+	}
+
+	if perr.Service == "" {
+		perr.Service = service
+	}
+	perr.Latency = latency
+
+	return perr
+}
@@ -0,0 +1,78 @@
+package data_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixture is a canned upstream response, loaded from the testdata directory
+type fixture struct {
+	status int    // The HTTP status code to respond with
+	file   string // The file (relative to testdata) with the response body
+}
+
+// loadFixture reads a file from the testdata directory
+func loadFixture(t testing.TB, name string) []byte {
+	t.Helper()
+
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Unable to read fixture %s: %v", name, err)
+	}
+
+	return contents
+}
+
+// newFixtureServer starts a test server that answers each request path with
+// its fixture (and a 404 for anything else).  Callers should Close() it
+func newFixtureServer(t *testing.T, routes map[string]fixture) *httptest.Server {
+	t.Helper()
+
+	//	Load everything up front, so a missing fixture fails the test right away
+	bodies := map[string][]byte{}
+	for path, f := range routes {
+		if f.file != "" {
+			bodies[path] = loadFixture(t, f.file)
+		}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		f, ok := routes[req.URL.Path]
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+
+		if f.status == 0 {
+			f.status = http.StatusOK
+		}
+
+		rw.WriteHeader(f.status)
+		rw.Write(bodies[req.URL.Path])
+	}))
+}
+
+// newClosedServerURL returns the url of a server that is no longer listening
+func newClosedServerURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	return server.URL
+}
+
+// mustLoadLocation loads the named timezone, or skips the test if the timezone
+// database isn't available
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("Timezone %s isn't available: %v", name, err)
+	}
+
+	return location
+}
@@ -0,0 +1,174 @@
+package data
+
+import (
+	"math"
+	"time"
+)
+
+// DateFormat is the format used for forecast calendar dates
+const DateFormat = "2006-01-02"
+
+// Category is the human friendly level for a pollen index
+type Category string
+
+const (
+	// CategoryLow is the lowest pollen level
+	CategoryLow Category = "Low"
+
+	// CategoryLowMedium is between low and medium
+	CategoryLowMedium Category = "Low-Medium"
+
+	// CategoryMedium is the middle pollen level
+	CategoryMedium Category = "Medium"
+
+	// CategoryMediumHigh is between medium and high
+	CategoryMediumHigh Category = "Medium-High"
+
+	// CategoryHigh is the highest pollen level
+	CategoryHigh Category = "High"
+)
+
+// categories are the pollen levels, from lowest to highest
+var categories = []Category{CategoryLow, CategoryLowMedium, CategoryMedium, CategoryMediumHigh, CategoryHigh}
+
+// IndexScale describes the native scale a service reports its pollen index on
+type IndexScale struct {
+	Name       string    `json:"name"`                 // The name of the scale
+	Min        float64   `json:"min"`                  // The lowest possible index
+	Max        float64   `json:"max"`                  // The highest possible index
+	Units      string    `json:"units,omitempty"`      // What the index measures
+	Thresholds []float64 `json:"thresholds,omitempty"` // The lowest index for each category above Low (in order)
+}
+
+// CanonicalScale is the 0-12 scale every report is normalized onto
+var CanonicalScale = IndexScale{
+	Name:       "Canonical",
+	Min:        0,
+	Max:        12,
+	Units:      "index",
+	Thresholds: []float64{2.5, 4.9, 7.3, 9.7},
+}
+
+// Category returns the pollen level for an index on this scale.  If the scale
+// doesn't have thresholds it's split into equal bands, one for each level
+func (s IndexScale) Category(index float64) Category {
+	return categories[s.band(index)]
+}
+
+// Normalize converts an index on this scale to the canonical scale.  Each
+// category band maps linearly onto the same band of the canonical scale, so the
+// category doesn't change
+func (s IndexScale) Normalize(index float64) float64 {
+	edges := s.edges()
+	canonical := CanonicalScale.edges()
+	band := s.band(index)
+
+	//	Clamp to the scale
+	if index < s.Min {
+		index = s.Min
+	}
+	if index > s.Max {
+		index = s.Max
+	}
+
+	position := 0.0
+	if width := edges[band+1] - edges[band]; width > 0 {
+		position = (index - edges[band]) / width
+	}
+
+	normalized := canonical[band] + position*(canonical[band+1]-canonical[band])
+
+	//	Pollen indices are reported to one decimal place
+	return math.Round(normalized*10) / 10
+}
+
+// band returns the category band (0 for Low) an index falls in
+func (s IndexScale) band(index float64) int {
+	edges := s.edges()
+
+	band := 0
+	for band < len(categories)-1 && index >= edges[band+1] {
+		band++
+	}
+
+	return band
+}
+
+// edges returns the lower edge of each category band, followed by the scale maximum
+func (s IndexScale) edges() []float64 {
+	edges := []float64{s.Min}
+
+	if len(s.Thresholds) == len(categories)-1 {
+		edges = append(edges, s.Thresholds...)
+	} else {
+		width := (s.Max - s.Min) / float64(len(categories))
+		for i := 1; i < len(categories); i++ {
+			edges = append(edges, s.Min+width*float64(i))
+		}
+	}
+
+	return append(edges, s.Max)
+}
+
+// ScaledService is implemented by services that declare the native scale they
+// report their pollen index on
+type ScaledService interface {
+	// Scale returns the native scale for the service
+	Scale() IndexScale
+}
+
+// ServiceScale returns the native scale for the service, or the canonical
+// scale if the service doesn't declare one
+func ServiceScale(service PollenService) IndexScale {
+	if scaled, ok := service.(ScaledService); ok {
+		return scaled.Scale()
+	}
+
+	return CanonicalScale
+}
+
+// normalizeReport fills in the canonical indices for the report data, using
+// the service's native scale
+func normalizeReport(report PollenReport, scale IndexScale) PollenReport {
+	report.Normalized = []float64{}
+	for _, index := range report.Data {
+		report.Normalized = append(report.Normalized, scale.Normalize(index))
+	}
+
+	return report
+}
+
+// ForecastDay is the pollen forecast for a single calendar day
+type ForecastDay struct {
+	Date     string     `json:"date"`     // The calendar date (YYYY-MM-DD) in the location's timezone
+	Index    float64    `json:"index"`    // The pollen index, on the service's native scale
+	Scale    IndexScale `json:"scale"`    // The service's native scale
+	Category Category   `json:"category"` // The pollen level for the index
+
+	Normalized float64                `json:"normalized"`        // The pollen index, on the canonical scale
+	Plants     *PlantIndices          `json:"plants,omitempty"`  // The sub-indices by kind of plant (if the service reports them)
+	Species    []SpeciesConcentration `json:"species,omitempty"` // The concentration of each pollen species (if the service reports them)
+}
+
+// newForecastDays builds the forecast days for the indices, starting with the
+// calendar day of start (which should be in the location's timezone)
+func newForecastDays(start time.Time, indices []float64, scale IndexScale) []ForecastDay {
+	days := []ForecastDay{}
+
+	for i, index := range indices {
+		days = append(days, newForecastDay(start.AddDate(0, 0, i).Format(DateFormat), index, scale))
+	}
+
+	return days
+}
+
+// newForecastDay builds the forecast for a single day
+func newForecastDay(date string, index float64, scale IndexScale) ForecastDay {
+	return ForecastDay{
+		Date:       date,
+		Index:      index,
+		Scale:      scale,
+		Category:   scale.Category(index),
+		Normalized: scale.Normalize(index),
+	}
+}
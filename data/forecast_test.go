@@ -0,0 +1,79 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/danesparza/pollen/data"
+)
+
+func TestIndexScale_Category_ReturnsLevelForIndex(t *testing.T) {
+	scale := data.IndexScale{Name: "test", Min: 0, Max: 12}
+
+	tests := []struct {
+		index    float64
+		expected data.Category
+	}{
+		{-1, data.CategoryLow},
+		{0, data.CategoryLow},
+		{2.3, data.CategoryLow},
+		{3.5, data.CategoryLowMedium},
+		{6, data.CategoryMedium},
+		{8.5, data.CategoryMediumHigh},
+		{10, data.CategoryHigh},
+		{12, data.CategoryHigh},
+		{15, data.CategoryHigh},
+	}
+
+	for _, tt := range tests {
+		if category := scale.Category(tt.index); category != tt.expected {
+			t.Errorf("Category(%v) = %s, expected %s", tt.index, category, tt.expected)
+		}
+	}
+}
+
+func TestIndexScale_Normalize_MapsOntoCanonicalScale(t *testing.T) {
+	//	A 0-5 scale with a category for each whole number
+	scale := data.IndexScale{Name: "test", Min: 0, Max: 5, Thresholds: []float64{1, 2, 3, 4}}
+
+	tests := []struct {
+		index    float64
+		expected float64
+	}{
+		{-1, 0},
+		{0, 0},
+		{1, 2.5},
+		{2.5, 6.1},
+		{4, 9.7},
+		{5, 12},
+		{7, 12},
+	}
+
+	for _, tt := range tests {
+		normalized := scale.Normalize(tt.index)
+		if normalized != tt.expected {
+			t.Errorf("Normalize(%v) = %v, expected %v", tt.index, normalized, tt.expected)
+		}
+
+		if scale.Category(tt.index) != data.CanonicalScale.Category(normalized) {
+			t.Errorf("Normalize(%v) changed the category from %s to %s", tt.index, scale.Category(tt.index), data.CanonicalScale.Category(normalized))
+		}
+	}
+}
+
+func TestIndexScale_Normalize_CanonicalScaleIsUnchanged(t *testing.T) {
+	for _, index := range []float64{0, 1.2, 2.5, 4.8, 7.3, 9.6, 12} {
+		if normalized := data.CanonicalScale.Normalize(index); normalized != index {
+			t.Errorf("Normalize(%v) = %v, expected it to be unchanged", index, normalized)
+		}
+	}
+}
+
+func TestServiceScale_DefaultsToCanonicalScale(t *testing.T) {
+	if scale := data.ServiceScale(fakeService{}); scale.Name != data.CanonicalScale.Name {
+		t.Errorf("Expected the canonical scale, but got %+v", scale)
+	}
+
+	if scale := data.ServiceScale(data.PollencomService{}); scale.Name != data.PollencomScale.Name {
+		t.Errorf("Expected the Pollen.com scale, but got %+v", scale)
+	}
+}
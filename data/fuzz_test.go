@@ -0,0 +1,153 @@
+//go:build go1.18
+// +build go1.18
+
+package data_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// fuzzTransport answers each request with the body for the first path
+// fragment it contains (or an empty object), without touching the network
+type fuzzTransport []struct {
+	fragment string
+	body     []byte
+}
+
+func (t fuzzTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := []byte("{}")
+	for _, route := range t {
+		if strings.Contains(req.URL.Path, route.fragment) {
+			body = route.body
+			break
+		}
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// fuzzClient returns a client that answers requests with the fuzzed bodies
+func fuzzClient(routes fuzzTransport) *http.Client {
+	return &http.Client{Transport: routes}
+}
+
+// checkFuzzedReport fails the test if a report that came back without an error
+// doesn't hang together
+func checkFuzzedReport(t *testing.T, report data.PollenReport, err error) {
+	t.Helper()
+
+	if err != nil {
+		if _, ok := err.(*data.ProviderError); !ok {
+			t.Errorf("Expected a *data.ProviderError, but got %T (%v)", err, err)
+		}
+		return
+	}
+
+	if len(report.Days) != len(report.Data) {
+		t.Errorf("Expected a day for each of the %d datapoints, but got %d", len(report.Data), len(report.Days))
+	}
+}
+
+func FuzzPollencom_GetPollenReport(f *testing.F) {
+	f.Add(loadFixture(f, "pollencom/forecast_30019.json"), loadFixture(f, "pollencom/current_30019.json"))
+	f.Add(loadFixture(f, "pollencom/forecast_trimmed.json"), loadFixture(f, "pollencom/current_trimmed.json"))
+	f.Add(loadFixture(f, "pollencom/unknown_zip.json"), loadFixture(f, "pollencom/current_yesterday.json"))
+	f.Add([]byte(`{"Location":{"periods":[{"Type":"Today"}]}}`), []byte(`{"Location":{"periods":[{"Type":"Tomorrow","Triggers":null}]}}`))
+
+	f.Fuzz(func(t *testing.T, forecast, current []byte) {
+		service := data.PollencomService{
+			Client:  fuzzClient(fuzzTransport{{"/extended/", forecast}, {"/current/", current}}),
+			BaseURL: "http://pollen.test",
+		}
+		ctx, seg := xray.BeginSegment(context.Background(), "fuzz-test")
+		defer seg.Close(nil)
+
+		report, err := service.GetPollenReport(ctx, "30019")
+
+		checkFuzzedReport(t, report, err)
+		if len(report.Data) > data.PollencomForecastDays {
+			t.Errorf("Expected at most %d days, but got %d", data.PollencomForecastDays, len(report.Data))
+		}
+	})
+}
+
+func FuzzOpenMeteo_GetPollenReport(f *testing.F) {
+	f.Add(loadFixture(f, "openmeteo/air_quality_10115.json"))
+	f.Add(loadFixture(f, "openmeteo/air_quality_no_pollen.json"))
+	f.Add([]byte(`{"hourly":{"time":["2019-04-18T00:00","bad"],"birch_pollen":[1]}}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		service := data.OpenMeteoService{
+			Client:   fuzzClient(fuzzTransport{{"/air-quality", body}}),
+			BaseURL:  "http://pollen.test",
+			Geocoder: data.StaticGeocoder{"30019": {Latitude: 33.98872, Longitude: -83.89796}},
+		}
+		ctx, seg := xray.BeginSegment(context.Background(), "fuzz-test")
+		defer seg.Close(nil)
+
+		report, err := service.GetPollenReport(ctx, "30019")
+
+		checkFuzzedReport(t, report, err)
+	})
+}
+
+func FuzzGoogle_GetPollenReport(f *testing.F) {
+	f.Add(loadFixture(f, "google/forecast_30019.json"))
+	f.Add(loadFixture(f, "google/forecast_empty.json"))
+	f.Add([]byte(`{"dailyInfo":[{"pollenTypeInfo":[{"code":"TREE"}],"plantInfo":[{"indexInfo":{"value":2}}]}]}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		service := data.GoogleService{
+			APIKey:   googleTestKey,
+			Client:   fuzzClient(fuzzTransport{{"/forecast:lookup", body}}),
+			BaseURL:  "http://pollen.test",
+			Geocoder: googleTestGeocoder,
+		}
+		ctx, seg := xray.BeginSegment(context.Background(), "fuzz-test")
+		defer seg.Close(nil)
+
+		report, err := service.GetPollenReport(ctx, "30019")
+
+		checkFuzzedReport(t, report, err)
+	})
+}
+
+func FuzzConfigurablePollenService_Extract(f *testing.F) {
+	f.Add(loadFixture(f, "configurable/regional_30019.json"))
+	f.Add([]byte(`{"outlook":[{"day":"2019-04-18","level":"NaN","top":"Oak and Pine"}]}`))
+	f.Add([]byte(`{"outlook":[{"level":1},{"level":2,"day":"2019-04-19"}]}`))
+
+	service, err := data.NewConfigurablePollenService(data.ProviderDefinition{
+		Name: "Regional Pollen",
+		URL:  "https://pollen.example.com/{zip}",
+		Mappings: data.ProviderMappings{
+			Indices:   "outlook[].level",
+			Dates:     "outlook[].day",
+			Location:  "station.city",
+			Allergens: "outlook[0].top",
+		},
+	})
+	if err != nil {
+		f.Fatalf("Unable to create the service: %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		report, err := service.Extract("30019", body)
+
+		checkFuzzedReport(t, report, err)
+	})
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpenMeteoGeocodingURL is the default base url for the Open-Meteo geocoding API
+const OpenMeteoGeocodingURL = "https://geocoding-api.open-meteo.com"
+
+// Coordinates are where a zipcode is
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`  // The latitude, in degrees
+	Longitude float64 `json:"longitude"` // The longitude, in degrees
+	Location  string  `json:"location"`  // The city/state location (if it's known)
+}
+
+// Geocoder resolves zipcodes to coordinates, for services that need a
+// latitude and longitude
+type Geocoder interface {
+	// Geocode returns the coordinates for the zipcode
+	Geocode(ctx context.Context, zipcode string) (Coordinates, error)
+}
+
+// StaticGeocoder resolves zipcodes from a fixed list of coordinates
+type StaticGeocoder map[string]Coordinates
+
+// Geocode returns the coordinates for the zipcode, if it's in the list
+func (g StaticGeocoder) Geocode(ctx context.Context, zipcode string) (Coordinates, error) {
+	coordinates, ok := g[NormalizeZipcode(zipcode)]
+	if !ok {
+		return Coordinates{}, fmt.Errorf("There are no coordinates for zipcode %s", zipcode)
+	}
+
+	return coordinates, nil
+}
+
+// ReadStaticGeocoder reads a static geocoder from a JSON file that maps each
+// zipcode to its coordinates, like {"30019": {"latitude": 33.98872,
+// "longitude": -83.89796, "location": "Dacula, GA"}}
+func ReadStaticGeocoder(path string) (StaticGeocoder, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem reading the coordinates: %s", err)
+	}
+
+	geocoder := StaticGeocoder{}
+	if err := json.Unmarshal(contents, &geocoder); err != nil {
+		return nil, fmt.Errorf("There was a problem decoding the coordinates %s: %s", path, err)
+	}
+
+	return geocoder, nil
+}
+
+// OpenMeteoGeocoder resolves postal codes with the Open-Meteo geocoding API
+type OpenMeteoGeocoder struct {
+	Client  *http.Client // The HTTP client to use (optional -- defaults to an X-Ray instrumented client)
+	BaseURL string       // The base url for the API (optional -- defaults to OpenMeteoGeocodingURL)
+	Country string       // The ISO country code the postal codes are in (optional -- defaults to ZipcodeCountry)
+}
+
+// OpenMeteoGeocodingResponse is the native geocoding API return format
+type OpenMeteoGeocodingResponse struct {
+	Results []struct {
+		Name        string   `json:"name"`
+		Latitude    float64  `json:"latitude"`
+		Longitude   float64  `json:"longitude"`
+		CountryCode string   `json:"country_code"`
+		Admin1      string   `json:"admin1"`
+		Postcodes   []string `json:"postcodes"`
+	} `json:"results"`
+}
+
+// Geocode returns the coordinates for the zipcode
+func (g OpenMeteoGeocoder) Geocode(ctx context.Context, zipcode string) (Coordinates, error) {
+	zipcode = NormalizeZipcode(zipcode)
+
+	country := strings.ToUpper(strings.TrimSpace(g.Country))
+	if country == "" {
+		country = ZipcodeCountry
+	}
+
+	query := url.Values{}
+	query.Set("name", zipcode)
+	query.Set("countryCode", country)
+	query.Set("count", "1")
+	query.Set("language", "en")
+	query.Set("format", "json")
+	apiurl := fmt.Sprintf("%s/v1/search?%s", baseURL(g.BaseURL, OpenMeteoGeocodingURL), query.Encode())
+
+	payload, err := fetchPayload(ctx, g.Client, "Open-Meteo", "Open-Meteo geocoding", apiurl)
+	if err != nil {
+		return Coordinates{}, err
+	}
+
+	response := OpenMeteoGeocodingResponse{}
+	if err := json.Unmarshal(payload.Body, &response); err != nil {
+		return Coordinates{}, &ProviderError{
+			Service: "Open-Meteo",
+			Reason:  FailureDecode,
+			Err:     fmt.Errorf("There was a problem decoding the response from the Open-Meteo geocoding API: %s", err),
+		}
+	}
+
+	if len(response.Results) == 0 {
+		return Coordinates{}, &ProviderError{
+			Service: "Open-Meteo",
+			Reason:  FailureInsufficientData,
+			Err:     fmt.Errorf("The Open-Meteo geocoding API doesn't know zipcode %s", zipcode),
+		}
+	}
+
+	result := response.Results[0]
+
+	//	Use the state abbreviation, like the other services (or the country
+	//	code, outside the US)
+	state := result.CountryCode
+	if country == ZipcodeCountry {
+		state = zipcodeState(zipcode)
+		if state == "" {
+			state = result.Admin1
+		}
+	}
+
+	return Coordinates{
+		Latitude:  result.Latitude,
+		Longitude: result.Longitude,
+		Location:  strings.Trim(fmt.Sprintf("%s, %s", result.Name, state), ", "),
+	}, nil
+}
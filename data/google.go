@@ -0,0 +1,320 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// GoogleBaseURL is the default base url for the Google Pollen API
+const GoogleBaseURL = "https://pollen.googleapis.com"
+
+// GoogleForecastDays is how many days of forecast are requested from Google
+const GoogleForecastDays = 4
+
+// GoogleScale is the native scale for Google: the Universal Pollen Index (UPI),
+// from 0 (none) to 5 (very high)
+var GoogleScale = IndexScale{
+	Name:       "Google",
+	Min:        0,
+	Max:        5,
+	Units:      "UPI",
+	Thresholds: []float64{1.5, 2.5, 3.5, 4.5},
+}
+
+// GoogleService is a pollen service for the Google Pollen API.  It needs an API
+// key, and coordinates -- so zipcodes are resolved with its Geocoder first
+type GoogleService struct {
+	APIKey   string       // The Google Maps Platform API key (with the Pollen API turned on)
+	Client   *http.Client // The HTTP client to use (optional -- defaults to an X-Ray instrumented client)
+	BaseURL  string       // The base url for the API (optional -- defaults to GoogleBaseURL)
+	Geocoder Geocoder     // How zipcodes are resolved to coordinates (optional -- defaults to an OpenMeteoGeocoder with the same client)
+}
+
+// googleIndexInfo is an index value in the Google Pollen API
+type googleIndexInfo struct {
+	Code        string  `json:"code"`
+	DisplayName string  `json:"displayName"`
+	Value       float64 `json:"value"`
+	Category    string  `json:"category"`
+}
+
+// GoogleForecastResponse is the native service return format
+type GoogleForecastResponse struct {
+	RegionCode string `json:"regionCode"`
+	DailyInfo  []struct {
+		Date struct {
+			Year  int `json:"year"`
+			Month int `json:"month"`
+			Day   int `json:"day"`
+		} `json:"date"`
+		PollenTypeInfo []struct {
+			Code        string           `json:"code"`
+			DisplayName string           `json:"displayName"`
+			InSeason    bool             `json:"inSeason"`
+			IndexInfo   *googleIndexInfo `json:"indexInfo"`
+		} `json:"pollenTypeInfo"`
+		PlantInfo []struct {
+			Code             string           `json:"code"`
+			DisplayName      string           `json:"displayName"`
+			InSeason         bool             `json:"inSeason"`
+			IndexInfo        *googleIndexInfo `json:"indexInfo"`
+			PlantDescription *struct {
+				Type           string `json:"type"`
+				Family         string `json:"family"`
+				Season         string `json:"season"`
+				SpecialColors  string `json:"specialColors"`
+				SpecialShapes  string `json:"specialShapes"`
+				CrossReaction  string `json:"crossReaction"`
+				Picture        string `json:"picture"`
+				PictureCloseup string `json:"pictureCloseup"`
+			} `json:"plantDescription"`
+		} `json:"plantInfo"`
+	} `json:"dailyInfo"`
+}
+
+// GoogleErrorResponse is the native service error format
+type GoogleErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// Name returns the name of the service
+func (s GoogleService) Name() string {
+	return "Google"
+}
+
+// Scale returns the native scale for the service
+func (s GoogleService) Scale() IndexScale {
+	return GoogleScale
+}
+
+// GetPollenReport gets the pollen report
+func (s GoogleService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "google-service")
+
+	//	Our return value
+	retval := PollenReport{}
+
+	coordinates, payload, err := s.fetch(ctx, zipcode)
+	if err != nil {
+		seg.AddError(err)
+		return retval, err
+	}
+
+	//	Decode the return object
+	serviceResponse := GoogleForecastResponse{}
+	if err := json.Unmarshal(payload.Body, &serviceResponse); err != nil {
+		seg.AddError(err)
+		return retval, &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureDecode,
+			Err:     fmt.Errorf("There was a problem decoding the response from the Google Pollen API: %s", err),
+		}
+	}
+
+	//	Parse the data items (the highest index for any kind of pollen each day):
+	dataitems := []float64{}
+	days := []ForecastDay{}
+	for _, daily := range serviceResponse.DailyInfo {
+		index := 0.0
+		plants := &PlantIndices{}
+		for _, pollenType := range daily.PollenTypeInfo {
+			if pollenType.IndexInfo == nil {
+				continue
+			}
+
+			value := pollenType.IndexInfo.Value
+			if value > index {
+				index = value
+			}
+
+			switch parsePlantType(pollenType.Code) {
+			case PlantTree:
+				plants.Tree = floatPointer(value)
+			case PlantGrass:
+				plants.Grass = floatPointer(value)
+			case PlantWeed:
+				plants.Weed = floatPointer(value)
+			}
+		}
+
+		day := newForecastDay(fmt.Sprintf("%04d-%02d-%02d", daily.Date.Year, daily.Date.Month, daily.Date.Day), index, GoogleScale)
+		day.Plants = plants
+
+		dataitems = append(dataitems, index)
+		days = append(days, day)
+	}
+
+	//	Google only has pollen where (and when) it's modeled
+	if len(days) == 0 {
+		err := &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureInsufficientData,
+			Err:     fmt.Errorf("Google doesn't have pollen data for %s", zipcode),
+		}
+		seg.AddError(err)
+		return retval, err
+	}
+
+	//	The predominant pollen is today's plants that are in the air, highest index first
+	predomPollens := []string{}
+	allergens := []Allergen{}
+	descriptions := []PlantDescription{}
+	for _, plant := range serviceResponse.DailyInfo[0].PlantInfo {
+		description := PlantDescription{Allergen: Allergen{Name: plant.DisplayName}}
+		if plant.PlantDescription != nil {
+			description.PlantType = parsePlantType(plant.PlantDescription.Type)
+			description.Family = plant.PlantDescription.Family
+			description.Season = plant.PlantDescription.Season
+			description.SpecialColors = plant.PlantDescription.SpecialColors
+			description.SpecialShapes = plant.PlantDescription.SpecialShapes
+			description.CrossReaction = plant.PlantDescription.CrossReaction
+			description.Picture = plant.PlantDescription.Picture
+			description.PictureCloseup = plant.PlantDescription.PictureCloseup
+		}
+
+		//	Prefer the catalog (ragweed is a weed to Google, but it has its own plant type)
+		if known := lookupPlural(description.Name); known.PlantType != "" {
+			description.Genus = known.Genus
+			description.PlantType = known.PlantType
+		}
+
+		if plant.IndexInfo != nil {
+			description.Index = floatPointer(plant.IndexInfo.Value)
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	//	Most pollen first (the descriptions stay in Google's order)
+	inAir := []PlantDescription{}
+	for _, description := range descriptions {
+		if description.Index != nil && *description.Index > 0 {
+			inAir = append(inAir, description)
+		}
+	}
+	sort.SliceStable(inAir, func(i, j int) bool {
+		return *inAir[i].Index > *inAir[j].Index
+	})
+
+	for _, description := range inAir {
+		predomPollens = append(predomPollens, description.Name)
+		allergens = append(allergens, description.Allergen)
+	}
+
+	//	Set the properties in the return object:
+	retval = PollenReport{
+		ReportingService:  s.Name(),
+		PredominantPollen: strings.Join(predomPollens, ", "),
+		Zipcode:           zipcode,
+		Location:          coordinates.Location,
+		StartDate:         time.Now(),
+		Data:              dataitems,
+		Days:              days,
+		Allergens:         allergens,
+		PlantDescriptions: descriptions,
+	}
+
+	xray.AddMetadata(ctx, "GoogleResult", retval)
+
+	// Close the segment
+	seg.Close(nil)
+
+	return retval, nil
+}
+
+// GetRawReport gets the forecast API response the pollen report is built from
+func (s GoogleService) GetRawReport(ctx context.Context, zipcode string) ([]RawPayload, error) {
+	_, payload, err := s.fetch(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return []RawPayload{payload}, nil
+}
+
+// fetch resolves the zipcode's coordinates and calls the forecast API for them.
+// The API key is sent in a header, so it never shows up in urls
+func (s GoogleService) fetch(ctx context.Context, zipcode string) (Coordinates, RawPayload, error) {
+	if s.APIKey == "" {
+		return Coordinates{}, RawPayload{}, &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureConfig,
+			Err:     errors.New("There's no API key for the Google Pollen API"),
+		}
+	}
+
+	geocoder := s.Geocoder
+	if geocoder == nil {
+		geocoder = OpenMeteoGeocoder{Client: s.Client}
+	}
+
+	coordinates, err := geocoder.Geocode(ctx, zipcode)
+	if err != nil {
+		//	The geocoder is another service, so its failures are reported as ours (for the same reason)
+		perr := &ProviderError{Service: s.Name(), Reason: FailureInsufficientData, Err: err}
+		if geocodeErr, ok := err.(*ProviderError); ok {
+			perr.Reason = geocodeErr.Reason
+			perr.StatusCode = geocodeErr.StatusCode
+		}
+		return Coordinates{}, RawPayload{}, perr
+	}
+
+	query := url.Values{}
+	query.Set("location.latitude", fmt.Sprintf("%.4f", coordinates.Latitude))
+	query.Set("location.longitude", fmt.Sprintf("%.4f", coordinates.Longitude))
+	query.Set("days", fmt.Sprintf("%d", GoogleForecastDays))
+	query.Set("plantsDescription", "true")
+	query.Set("languageCode", "en")
+	apiurl := fmt.Sprintf("%s/v1/forecast:lookup?%s", baseURL(s.BaseURL, GoogleBaseURL), query.Encode())
+
+	req, _ := http.NewRequest("GET", apiurl, nil)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Goog-Api-Key", s.APIKey)
+
+	payload, err := doPayload(ctx, s.Client, s.Name(), "Google Pollen", req, func(resp *http.Response, body []byte) *ProviderError {
+		return googleError(s.Name(), resp, body)
+	})
+	if err != nil {
+		return Coordinates{}, RawPayload{}, err
+	}
+
+	payload.Name = "forecast"
+	return coordinates, payload, nil
+}
+
+// googleError returns the provider error for a Google Pollen API error
+// response.  Running out of quota has its own reason, since retrying won't help
+func googleError(service string, resp *http.Response, body []byte) *ProviderError {
+	googleErr := GoogleErrorResponse{}
+	json.Unmarshal(body, &googleErr)
+
+	message := googleErr.Error.Message
+	if message == "" {
+		message = resp.Status
+	}
+
+	reason := FailureHTTPStatus
+	if resp.StatusCode == http.StatusTooManyRequests || googleErr.Error.Status == "RESOURCE_EXHAUSTED" {
+		reason = FailureQuota
+	}
+
+	return &ProviderError{
+		Service:    service,
+		Reason:     reason,
+		StatusCode: resp.StatusCode,
+		Err:        fmt.Errorf("There was an error getting information from the Google Pollen API: %s", message),
+	}
+}
@@ -0,0 +1,215 @@
+package data_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+const (
+	googleForecastPath = "/v1/forecast:lookup"
+	googleTestKey      = "test-api-key"
+)
+
+// googleTestGeocoder resolves the test zipcode without calling a geocoding API
+var googleTestGeocoder = data.StaticGeocoder{"30019": {Latitude: 33.98872, Longitude: -83.89796, Location: "Dacula, GA"}}
+
+// newGoogleServer starts a stand-in for the Google Pollen API that answers the
+// forecast with the fixture -- or with the invalid key fixture if the request
+// doesn't have the test API key.  Callers should Close() it
+func newGoogleServer(t *testing.T, f fixture) *httptest.Server {
+	t.Helper()
+
+	body := loadFixture(t, f.file)
+	invalidKey := loadFixture(t, "google/invalid_key.json")
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != googleForecastPath {
+			http.NotFound(rw, req)
+			return
+		}
+
+		if req.Header.Get("X-Goog-Api-Key") != googleTestKey || req.URL.Query().Get("key") != "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write(invalidKey)
+			return
+		}
+
+		if f.status == 0 {
+			f.status = http.StatusOK
+		}
+
+		rw.WriteHeader(f.status)
+		rw.Write(body)
+	}))
+}
+
+func TestGoogle_GetPollenReport_ReturnsValidData(t *testing.T) {
+	//	Arrange
+	server := newGoogleServer(t, fixture{file: "google/forecast_30019.json"})
+	defer server.Close()
+
+	service := data.GoogleService{APIKey: googleTestKey, Client: server.Client(), BaseURL: server.URL, Geocoder: googleTestGeocoder}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	//	The highest plant type index each day
+	if expected := []float64{4, 3, 2, 1}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected data %v, but got %v", expected, response.Data)
+	}
+
+	if response.Location != "Dacula, GA" {
+		t.Errorf("Expected location 'Dacula, GA', but got '%s'", response.Location)
+	}
+
+	if len(response.Days) != 4 || response.Days[0].Date != "2019-04-18" || response.Days[0].Category != data.CategoryMediumHigh {
+		t.Fatalf("Expected 4 forecast days starting on a medium-high 2019-04-18, but got %+v", response.Days)
+	}
+
+	today := response.Days[0]
+	if today.Plants == nil || today.Plants.Tree == nil || *today.Plants.Tree != 4 || today.Plants.Grass == nil || *today.Plants.Grass != 2 || today.Plants.Weed != nil {
+		t.Errorf("Expected tree and grass sub-indices (and no weed index out of season), but got %+v", today.Plants)
+	}
+
+	if response.PredominantPollen != "Oak, Birch, Grasses, Pine" {
+		t.Errorf("Expected the plants in the air, highest index first, but got '%s'", response.PredominantPollen)
+	}
+
+	if grass := response.Allergens[2]; grass.Genus != "Poaceae" || grass.PlantType != data.PlantGrass {
+		t.Errorf("Expected grasses to be matched to the catalog, but got %+v", grass)
+	}
+
+	if len(response.PlantDescriptions) != 6 {
+		t.Fatalf("Expected a description for all 6 plants, but got %+v", response.PlantDescriptions)
+	}
+
+	ragweed := response.PlantDescriptions[4]
+	if ragweed.Name != "Ragweed" || ragweed.PlantType != data.PlantRagweed || ragweed.Index != nil || ragweed.Family != "Asteraceae" || ragweed.CrossReaction != "Mugwort" {
+		t.Errorf("Expected the out of season ragweed description, but got %+v", ragweed)
+	}
+}
+
+func TestGoogle_GetPollenReport_NoAPIKey_ReturnsConfigError(t *testing.T) {
+	//	Arrange
+	service := data.GoogleService{BaseURL: newClosedServerURL(), Geocoder: googleTestGeocoder}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailureConfig || perr.Service != "Google" {
+		t.Errorf("Expected a config error from Google, but got %T (%v)", err, err)
+	}
+}
+
+func TestGoogle_GetPollenReport_GeocoderFails_ReturnsGoogleError(t *testing.T) {
+	//	Arrange
+	service := data.GoogleService{APIKey: googleTestKey, BaseURL: newClosedServerURL(), Geocoder: data.OpenMeteoGeocoder{BaseURL: newClosedServerURL()}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	perr, ok := err.(*data.ProviderError)
+	if !ok || perr.Service != "Google" || perr.Reason != data.FailureTransport {
+		t.Fatalf("Expected a transport error from Google, but got %T (%v)", err, err)
+	}
+
+	if cause, ok := perr.Err.(*data.ProviderError); !ok || cause.Service != "Open-Meteo" {
+		t.Errorf("Expected the geocoder's error as the cause, but got %T (%v)", perr.Err, perr.Err)
+	}
+}
+
+func TestGoogle_Registry_UsesCoordinatesFile(t *testing.T) {
+	//	Arrange
+	settings := data.ProviderSettings{APIKey: googleTestKey, Coordinates: "testdata/google/coordinates.json"}
+
+	//	Act
+	service, err := data.DefaultRegistry.New("google", settings)
+
+	//	Assert
+	google, ok := service.(data.GoogleService)
+	if err != nil || !ok || google.Geocoder == nil {
+		t.Fatalf("Expected Google with a static geocoder, but got %+v (%v)", service, err)
+	}
+
+	if coordinates, err := google.Geocoder.Geocode(context.Background(), "30019-1234"); err != nil || coordinates.Location != "Dacula, GA" {
+		t.Errorf("Expected the coordinates from the file, but got %+v (%v)", coordinates, err)
+	}
+}
+
+func TestGoogle_GetPollenReport_QuotaExceeded_ReturnsQuotaError(t *testing.T) {
+	//	Arrange
+	server := newGoogleServer(t, fixture{status: http.StatusTooManyRequests, file: "google/quota_exceeded.json"})
+	defer server.Close()
+
+	service := data.GoogleService{APIKey: googleTestKey, Client: server.Client(), BaseURL: server.URL, Geocoder: googleTestGeocoder}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	perr, ok := err.(*data.ProviderError)
+	if !ok || perr.Reason != data.FailureQuota || perr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected a 429 quota error, but got %T (%v)", err, err)
+	}
+
+	if expected := "Google (quota 429): There was an error getting information from the Google Pollen API: Quota exceeded for quota metric 'Forecast requests' and limit 'Forecast requests per minute' of service 'pollen.googleapis.com'."; perr.Error() != expected {
+		t.Errorf("Expected the error message from Google, but got '%s'", perr.Error())
+	}
+}
+
+func TestGoogle_GetPollenReport_InvalidKey_ReturnsHTTPStatus(t *testing.T) {
+	//	Arrange
+	server := newGoogleServer(t, fixture{file: "google/forecast_30019.json"})
+	defer server.Close()
+
+	service := data.GoogleService{APIKey: "not-the-key", Client: server.Client(), BaseURL: server.URL, Geocoder: googleTestGeocoder}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailureHTTPStatus || perr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a 400 status error, but got %T (%v)", err, err)
+	}
+}
+
+func TestGoogle_GetPollenReport_NoDays_ReturnsInsufficientData(t *testing.T) {
+	//	Arrange
+	server := newGoogleServer(t, fixture{file: "google/forecast_empty.json"})
+	defer server.Close()
+
+	service := data.GoogleService{APIKey: googleTestKey, Client: server.Client(), BaseURL: server.URL, Geocoder: googleTestGeocoder}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailureInsufficientData {
+		t.Errorf("Expected an insufficient data error, but got %T (%v)", err, err)
+	}
+}
@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// DefaultHedgeDelay is how long the hedged strategy waits on a tier before
+// calling the next one, when it doesn't set a delay
+const DefaultHedgeDelay = time.Second
+
+// PrioritizedService is implemented by services that know their priority.
+// Lower priorities are called first, and services with the same priority are
+// called together.  A service's own priority wins over the strategy's
+// Priorities
+type PrioritizedService interface {
+	// Priority returns the service's priority tier
+	Priority() int
+}
+
+// ServicePriority returns the priority of the passed service, and true if it
+// has one.  Wrapped services (like cached or resilient services) have the
+// priority of the service they wrap
+func ServicePriority(service PollenService) (int, bool) {
+	for {
+		if prioritized, ok := service.(PrioritizedService); ok {
+			return prioritized.Priority(), true
+		}
+
+		wrapper, ok := service.(interface{ Unwrap() PollenService })
+		if !ok {
+			return 0, false
+		}
+		service = wrapper.Unwrap()
+	}
+}
+
+// WithPriority returns the service with the given priority tier.  It only has
+// the service's name and scale -- not its other capabilities
+func WithPriority(service PollenService, priority int) PollenService {
+	return prioritizedService{PollenService: service, priority: priority}
+}
+
+// prioritizedService is a service with a priority tier
+type prioritizedService struct {
+	PollenService
+	priority int
+}
+
+// Name returns the name of the service
+func (s prioritizedService) Name() string {
+	return ServiceName(s.PollenService)
+}
+
+// Scale returns the native scale of the service
+func (s prioritizedService) Scale() IndexScale {
+	return ServiceScale(s.PollenService)
+}
+
+// Unwrap returns the service with the priority
+func (s prioritizedService) Unwrap() PollenService {
+	return s.PollenService
+}
+
+// Priority returns the service's priority tier
+func (s prioritizedService) Priority() int {
+	return s.priority
+}
+
+// Hedged is the strategy that calls the services a tier at a time: the first
+// tier is called right away, and the next tier is only called if there's no
+// valid report within Delay (or every service called so far has failed).  The
+// first valid report wins.  Services without a priority are tiered by the order
+// they were passed in (starting at 0)
+type Hedged struct {
+	Delay      time.Duration  // How long to wait on a tier before calling the next one (optional -- defaults to DefaultHedgeDelay)
+	Priorities map[string]int // The priority tier for each service, by service name (optional)
+}
+
+// Aggregate gets the first valid pollen report, calling the tiers as needed
+func (s Hedged) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "pollen-hedged")
+	defer seg.Close(nil)
+
+	delay := s.Delay
+	if delay <= 0 {
+		delay = DefaultHedgeDelay
+	}
+
+	start := time.Now()
+	tiers := serviceTiers(services, s.Priorities)
+	ch := make(chan serviceResult, len(services))
+
+	//	Track the failures in the order the services were passed
+	failures := make([]*ProviderError, len(services))
+	launched, pending := 0, 0
+
+	launch := func() {
+		for _, index := range tiers[launched] {
+			go func(i int) {
+				ch <- callService(ctx, i, services[i], zipcode)
+			}(index)
+		}
+		pending += len(tiers[launched])
+		launched++
+		xray.AddMetadata(ctx, "HedgedTiersCalled", launched)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	if len(tiers) > 0 {
+		launch()
+	}
+
+collect:
+	for pending > 0 {
+		select {
+		case result := <-ch:
+			//	Return the first valid result
+			if result.err == nil {
+				return result.report, nil
+			}
+			failures[result.index] = result.err
+			pending--
+
+			//	If everybody we called has failed, don't wait to call the next tier
+			if pending == 0 && launched < len(tiers) {
+				launch()
+				resetTimer(timer, delay)
+			}
+
+		case <-timer.C:
+			if launched < len(tiers) {
+				launch()
+				timer.Reset(delay)
+			}
+
+		case <-ctx.Done():
+			//	Anybody we're still waiting on (or didn't get to) has run out of time
+			timeoutPending(failures, nil, services, start, ctx.Err())
+			break collect
+		}
+	}
+
+	apperr := &MultiProviderError{Zipcode: zipcode, Errors: failures}
+	seg.AddError(apperr)
+
+	return PollenReport{}, apperr
+}
+
+// serviceTiers groups the indexes of the services by priority, lowest first.
+// Services without a priority of their own use the one named for them, or
+// their position in the list
+func serviceTiers(services []PollenService, named map[string]int) [][]int {
+	priorities := map[int][]int{}
+	order := []int{}
+	for index, service := range services {
+		priority, ok := ServicePriority(service)
+		if !ok {
+			priority, ok = named[ServiceName(service)]
+		}
+		if !ok {
+			priority = index
+		}
+
+		if _, seen := priorities[priority]; !seen {
+			order = append(order, priority)
+		}
+		priorities[priority] = append(priorities[priority], index)
+	}
+
+	sort.Ints(order)
+
+	tiers := [][]int{}
+	for _, priority := range order {
+		tiers = append(tiers, priorities[priority])
+	}
+
+	return tiers
+}
+
+// resetTimer stops the timer (draining it if it already fired) and resets it
+func resetTimer(timer *time.Timer, delay time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	timer.Reset(delay)
+}
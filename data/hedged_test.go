@@ -0,0 +1,149 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+func TestHedged_PrimaryAnswers_SkipsNextTier(t *testing.T) {
+	//	Arrange
+	var primaryCalls, secondaryCalls int32
+	services := []data.PollenService{
+		countingService{name: "Primary", calls: &primaryCalls, delay: 10 * time.Millisecond, report: data.PollenReport{ReportingService: "Primary", Data: []float64{1, 2}}},
+		countingService{name: "Secondary", calls: &secondaryCalls, report: data.PollenReport{ReportingService: "Secondary", Data: []float64{3, 4}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	report, err := data.Hedged{Delay: 500 * time.Millisecond}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil || report.ReportingService != "Primary" {
+		t.Errorf("Expected the primary's report, but got %+v (%v)", report, err)
+	}
+
+	if atomic.LoadInt32(&secondaryCalls) != 0 {
+		t.Errorf("Expected the secondary not to be called, but it was called %d times", secondaryCalls)
+	}
+}
+
+func TestHedged_PrimarySlow_HedgesWithNextTier(t *testing.T) {
+	//	Arrange
+	var primaryCalls, secondaryCalls int32
+	services := []data.PollenService{
+		countingService{name: "Primary", calls: &primaryCalls, delay: time.Second, report: data.PollenReport{ReportingService: "Primary", Data: []float64{1, 2}}},
+		countingService{name: "Secondary", calls: &secondaryCalls, report: data.PollenReport{ReportingService: "Secondary", Data: []float64{3, 4}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	start := time.Now()
+	report, err := data.Hedged{Delay: 50 * time.Millisecond}.Aggregate(ctx, services, "30019")
+	elapsed := time.Since(start)
+
+	//	Assert
+	if err != nil || report.ReportingService != "Secondary" {
+		t.Errorf("Expected the secondary's report, but got %+v (%v)", report, err)
+	}
+
+	if elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the secondary to be called after the hedge delay, but the report took %s", elapsed)
+	}
+}
+
+func TestHedged_PrimaryFails_CallsNextTierRightAway(t *testing.T) {
+	//	Arrange
+	var primaryCalls, secondaryCalls int32
+	services := []data.PollenService{
+		countingService{name: "Primary", calls: &primaryCalls, err: errors.New("down")},
+		countingService{name: "Secondary", calls: &secondaryCalls, report: data.PollenReport{ReportingService: "Secondary", Data: []float64{3, 4}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	start := time.Now()
+	report, err := data.Hedged{Delay: time.Second}.Aggregate(ctx, services, "30019")
+	elapsed := time.Since(start)
+
+	//	Assert
+	if err != nil || report.ReportingService != "Secondary" || elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the secondary's report without waiting for the hedge delay, but got %+v (%v) after %s", report, err, elapsed)
+	}
+}
+
+func TestHedged_Priorities_GroupTiers(t *testing.T) {
+	//	Arrange -- the backups share a tier, and the priority is seen through other wrappers
+	var primaryCalls, firstBackupCalls, secondBackupCalls int32
+	resilience := &data.Resilience{}
+	services := []data.PollenService{
+		data.WithPriority(countingService{name: "Backup 1", calls: &firstBackupCalls, delay: 20 * time.Millisecond, report: data.PollenReport{Data: []float64{3, 4}}}, 2),
+		resilience.Service(data.WithPriority(countingService{name: "Primary", calls: &primaryCalls, err: &data.ProviderError{Reason: data.FailureDecode}}, 1)),
+		data.WithPriority(countingService{name: "Backup 2", calls: &secondBackupCalls, delay: 20 * time.Millisecond, report: data.PollenReport{Data: []float64{3, 4}}}, 2),
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := data.Hedged{Delay: time.Second}.Aggregate(ctx, services, "30019")
+	time.Sleep(50 * time.Millisecond)
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected a backup's report, but got %v", err)
+	}
+
+	if atomic.LoadInt32(&primaryCalls) != 1 || atomic.LoadInt32(&firstBackupCalls) != 1 || atomic.LoadInt32(&secondBackupCalls) != 1 {
+		t.Errorf("Expected the primary, then both backups together, but got %d, %d and %d calls", primaryCalls, firstBackupCalls, secondBackupCalls)
+	}
+}
+
+func TestHedged_AllFail_ReturnsMultiProviderError(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "First", err: errors.New("down")},
+		fakeService{name: "Second", err: errors.New("also down")},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := data.Hedged{Delay: time.Second}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	merr, ok := err.(*data.MultiProviderError)
+	if !ok || len(merr.Errors) != 2 || merr.Errors[0].Service != "First" || merr.Errors[1].Service != "Second" {
+		t.Errorf("Expected a failure for each service, but got %T (%v)", err, err)
+	}
+}
+
+func TestHedged_NamedPriorities_CallsLowestTierFirst(t *testing.T) {
+	//	Arrange
+	var primaryCalls, secondaryCalls int32
+	services := []data.PollenService{
+		countingService{name: "Secondary", calls: &secondaryCalls, report: data.PollenReport{ReportingService: "Secondary", Data: []float64{3, 4}}},
+		countingService{name: "Primary", calls: &primaryCalls, report: data.PollenReport{ReportingService: "Primary", Data: []float64{1, 2}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	report, err := data.Hedged{Delay: 500 * time.Millisecond, Priorities: map[string]int{"Primary": 1, "Secondary": 2}}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil || report.ReportingService != "Primary" {
+		t.Errorf("Expected the primary's report, but got %+v (%v)", report, err)
+	}
+
+	if atomic.LoadInt32(&secondaryCalls) != 0 {
+		t.Errorf("Expected the secondary not to be called, but it was called %d times", secondaryCalls)
+	}
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HistoryDays is how many days of history are returned when no date range is given
+const HistoryDays = 30
+
+// History is the observed pollen for each day in a date range
+type History struct {
+	Location string        `json:"location"` // The location for the history
+	Start    string        `json:"start"`    // The first calendar date (YYYY-MM-DD) in the range
+	End      string        `json:"end"`      // The last calendar date (YYYY-MM-DD) in the range
+	Days     []ForecastDay `json:"days"`     // The observed pollen for each day, oldest first
+	Service  string        `json:"service"`  // The reporting service
+}
+
+// HistoryProvider is implemented by services that can fetch past pollen observations
+type HistoryProvider interface {
+	// GetPollenHistory gets the observed pollen for each day from start to end (inclusive)
+	GetPollenHistory(ctx context.Context, zipcode string, start, end time.Time) (History, error)
+}
+
+// HistoryRange fills in the defaults for a history date range: a zero end is
+// today, and a zero start is HistoryDays before the end.  It's an error for the
+// range to start after it ends
+func HistoryRange(start, end time.Time) (time.Time, time.Time, error) {
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -HistoryDays)
+	}
+
+	if start.Format(DateFormat) > end.Format(DateFormat) {
+		return start, end, fmt.Errorf("The history starts (%s) after it ends (%s)", start.Format(DateFormat), end.Format(DateFormat))
+	}
+
+	return start, end, nil
+}
+
+// GetPollenHistory calls all services that provide history in parallel and
+// returns the first result.  If start and end are zero, the last HistoryDays
+// days are returned.  A single day of history is enough.  If every service
+// fails (or none provide history) a *MultiProviderError is returned
+func GetPollenHistory(ctx context.Context, services []PollenService, zipcode string, start, end time.Time) (History, error) {
+	start, end, err := HistoryRange(start, end)
+	if err != nil {
+		return History{}, err
+	}
+
+	//	Adapt the services that provide history, so we can reuse GetPollenReport
+	adapted := []PollenService{}
+	for _, service := range services {
+		adaptedService, ok := adaptService(service, func(service PollenService) (PollenService, bool) {
+			provider, ok := service.(HistoryProvider)
+			if !ok {
+				return nil, false
+			}
+			return historyService{provider: provider, name: ServiceName(service), start: start, end: end}, true
+		})
+		if ok {
+			adapted = append(adapted, adaptedService)
+		}
+	}
+
+	report, err := GetPollenReport(ctx, adapted, zipcode)
+	if err != nil {
+		return History{}, err
+	}
+
+	return History{
+		Location: report.Location,
+		Start:    start.Format(DateFormat),
+		End:      end.Format(DateFormat),
+		Days:     report.Days,
+		Service:  report.ReportingService,
+	}, nil
+}
+
+// filterDays returns the days from start to end (inclusive, by calendar date)
+func filterDays(days []ForecastDay, start, end time.Time) []ForecastDay {
+	first := start.Format(DateFormat)
+	last := end.Format(DateFormat)
+
+	retval := []ForecastDay{}
+	for _, day := range days {
+		if day.Date >= first && day.Date <= last {
+			retval = append(retval, day)
+		}
+	}
+
+	return retval
+}
+
+// historyService adapts a HistoryProvider for a date range to a PollenService
+type historyService struct {
+	provider HistoryProvider
+	name     string
+	start    time.Time
+	end      time.Time
+}
+
+// Name returns the name of the adapted service
+func (s historyService) Name() string {
+	return s.name
+}
+
+// minDatapoints returns how many days of history make a valid result (unlike
+// a forecast, a single day is fine)
+func (s historyService) minDatapoints() int {
+	return 1
+}
+
+// GetPollenReport gets the history and returns it as a report
+func (s historyService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	history, err := s.provider.GetPollenHistory(ctx, zipcode, s.start, s.end)
+	if err != nil {
+		return PollenReport{}, err
+	}
+
+	retval := PollenReport{
+		Location:         history.Location,
+		Zipcode:          zipcode,
+		ReportingService: history.Service,
+		Days:             history.Days,
+	}
+
+	for _, day := range history.Days {
+		retval.Data = append(retval.Data, day.Index)
+	}
+
+	return retval, nil
+}
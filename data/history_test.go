@@ -0,0 +1,138 @@
+package data_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+const pollencomHistoricPath = "/api/forecast/historic/pollen/30019"
+
+func TestPollencom_GetPollenHistory_ReturnsDaysInRange(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomHistoricPath: {file: "pollencom/historic_30019.json"},
+	})
+	defer server.Close()
+
+	service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
+	start := time.Date(2019, 3, 29, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 3, 31, 0, 0, 0, 0, time.UTC)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	history, err := service.GetPollenHistory(ctx, "30019", start, end)
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenHistory: %v", err)
+	}
+
+	expected := []struct {
+		date  string
+		index float64
+	}{
+		{"2019-03-29", 8.7},
+		{"2019-03-30", 7.2},
+		{"2019-03-31", 3.6},
+	}
+
+	if len(history.Days) != len(expected) {
+		t.Fatalf("Expected %d days of history, but got %d", len(expected), len(history.Days))
+	}
+
+	for i, e := range expected {
+		if history.Days[i].Date != e.date || history.Days[i].Index != e.index {
+			t.Errorf("Expected day %d to be %s/%v, but got %+v", i, e.date, e.index, history.Days[i])
+		}
+	}
+
+	if history.Location != "DACULA, GA" || history.Start != "2019-03-29" || history.End != "2019-03-31" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+}
+
+func TestGetPollenHistory_DefaultsToLast30Days(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomHistoricPath: {file: "pollencom/historic_30019.json"},
+	})
+	defer server.Close()
+
+	services := []data.PollenService{
+		data.NasacortService{Client: server.Client(), BaseURL: server.URL},
+		data.PollencomService{Client: server.Client(), BaseURL: server.URL},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	history, err := data.GetPollenHistory(ctx, services, "30019", time.Time{}, time.Date(2019, 4, 18, 0, 0, 0, 0, time.UTC))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenHistory: %v", err)
+	}
+
+	if history.Service != "Pollen.com" || history.Start != "2019-03-19" || len(history.Days) != 30 {
+		t.Errorf("Expected 30 days of history from Pollen.com starting 2019-03-19, but got %d days from %s starting %s", len(history.Days), history.Service, history.Start)
+	}
+}
+
+func TestGetPollenHistory_NoHistoryProviders_ReturnsError(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{data.NasacortService{}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := data.GetPollenHistory(ctx, services, "30019", time.Time{}, time.Time{})
+
+	//	Assert
+	if _, ok := err.(*data.MultiProviderError); !ok {
+		t.Errorf("Expected a *data.MultiProviderError, but got %T (%v)", err, err)
+	}
+}
+
+func TestGetPollenHistory_SingleDay_ReturnsHistory(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomHistoricPath: {file: "pollencom/historic_30019.json"},
+	})
+	defer server.Close()
+
+	services := []data.PollenService{data.PollencomService{Client: server.Client(), BaseURL: server.URL}}
+	day := time.Date(2019, 3, 30, 0, 0, 0, 0, time.UTC)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	history, err := data.GetPollenHistory(ctx, services, "30019", day, day)
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenHistory: %v", err)
+	}
+
+	if len(history.Days) != 1 || history.Days[0].Date != "2019-03-30" {
+		t.Errorf("Expected a single day of history for 2019-03-30, but got %+v", history.Days)
+	}
+}
+
+func TestHistoryRange_StartAfterEnd_ReturnsError(t *testing.T) {
+	//	Arrange
+	start := time.Date(2019, 4, 18, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 3, 19, 0, 0, 0, 0, time.UTC)
+
+	//	Act
+	_, _, err := data.HistoryRange(start, end)
+
+	//	Assert
+	if err == nil {
+		t.Errorf("Expected an error for a range that starts after it ends")
+	}
+}
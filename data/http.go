@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// defaultClient is the X-Ray instrumented client services use when they
+// aren't given one
+var defaultClient = xray.Client(nil)
+
+// httpClient returns the passed client, or the default client if it's nil
+func httpClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+
+	return defaultClient
+}
+
+// baseURL returns the passed base url (without a trailing slash), or the
+// default url if it's blank
+func baseURL(url, defaultURL string) string {
+	if url == "" {
+		url = defaultURL
+	}
+
+	return strings.TrimSuffix(url, "/")
+}
+
+// statusError returns the provider error for an HTTP error response.  It's
+// given the response body, so services can pass along the API's own message
+type statusError func(resp *http.Response, body []byte) *ProviderError
+
+// fetchPayload GETs the url for the service and returns the response body.
+// Problems are returned as a *ProviderError for the service, describing the
+// API by name
+func fetchPayload(ctx context.Context, client *http.Client, service, name, apiurl string) (RawPayload, error) {
+	req, _ := http.NewRequest("GET", apiurl, nil)
+	req.Header.Add("Accept", "application/json")
+
+	return doPayload(ctx, client, service, name, req, nil)
+}
+
+// doPayload makes the request for the service and returns the response body.
+// Problems are returned as a *ProviderError for the service, describing the
+// API by name.  HTTP error responses are reported with onStatus, if it's
+// passed
+func doPayload(ctx context.Context, client *http.Client, service, name string, req *http.Request, onStatus statusError) (RawPayload, error) {
+	resp, err := ctxhttp.Do(ctx, httpClient(client), req)
+	if err != nil {
+		return RawPayload{}, &ProviderError{
+			Service: service,
+			Reason:  FailureTransport,
+			Err:     fmt.Errorf("There was a problem calling the %s API: %s", name, err),
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	//	If the HTTP status code indicates an error, report it and get out
+	if resp.StatusCode >= 400 {
+		if onStatus != nil {
+			return RawPayload{}, onStatus(resp, body)
+		}
+
+		return RawPayload{}, &ProviderError{
+			Service:    service,
+			Reason:     FailureHTTPStatus,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("There was an error getting information from the %s API: %s", name, resp.Status),
+		}
+	}
+
+	if err != nil {
+		return RawPayload{}, &ProviderError{
+			Service: service,
+			Reason:  FailureTransport,
+			Err:     fmt.Errorf("There was a problem reading the response from the %s API: %s", name, err),
+		}
+	}
+
+	return RawPayload{Name: name, URL: req.URL.String(), Body: body}, nil
+}
@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ForecastKind is the kind of health forecast
+type ForecastKind string
+
+const (
+	// KindPollen is the pollen forecast
+	KindPollen ForecastKind = "pollen"
+
+	// KindAsthma is the asthma forecast
+	KindAsthma ForecastKind = "asthma"
+
+	// KindColdFlu is the cold & flu forecast
+	KindColdFlu ForecastKind = "coldflu"
+)
+
+// ParseForecastKind returns the forecast kind for a (case insensitive) name
+func ParseForecastKind(name string) (ForecastKind, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "pollen":
+		return KindPollen, nil
+	case "asthma":
+		return KindAsthma, nil
+	case "coldflu", "cold-flu", "cold_flu", "cold", "flu":
+		return KindColdFlu, nil
+	}
+
+	return "", fmt.Errorf("Unknown forecast kind '%s'", name)
+}
+
+// Forecast is the day by day forecast for a single kind
+type Forecast struct {
+	Kind     ForecastKind  `json:"kind"`            // The kind of forecast
+	Location string        `json:"location"`        // The location for the forecast
+	Days     []ForecastDay `json:"days"`            // The forecast for each calendar day, starting with today
+	Service  string        `json:"service"`         // The reporting service
+	Error    string        `json:"error,omitempty"` // Why the forecast couldn't be fetched (if it failed)
+}
+
+// ForecastService is implemented by services that can fetch kinds of forecasts
+// beyond the pollen report
+type ForecastService interface {
+	// ForecastKinds returns the kinds of forecasts the service supports
+	ForecastKinds() []ForecastKind
+
+	// GetForecast gets the forecast of the given kind
+	GetForecast(ctx context.Context, kind ForecastKind, zipcode string) (Forecast, error)
+}
+
+// ForecastFromReport returns the day by day forecast in a pollen report
+func ForecastFromReport(report PollenReport) Forecast {
+	return Forecast{
+		Kind:     KindPollen,
+		Location: report.Location,
+		Days:     report.Days,
+		Service:  report.ReportingService,
+	}
+}
+
+// GetForecast calls all services that support the kind of forecast in parallel
+// and returns the first result.  If every service fails (or none support the
+// kind) a *MultiProviderError is returned
+func GetForecast(ctx context.Context, services []PollenService, kind ForecastKind, zipcode string) (Forecast, error) {
+	//	Adapt the services that support the kind, so we can reuse GetPollenReport
+	adapted := []PollenService{}
+	for _, service := range services {
+		adaptedService, ok := adaptService(service, func(service PollenService) (PollenService, bool) {
+			forecaster, ok := service.(ForecastService)
+			if !ok || !supportsKind(forecaster, kind) {
+				return nil, false
+			}
+			return kindService{forecaster: forecaster, name: ServiceName(service), kind: kind}, true
+		})
+		if ok {
+			adapted = append(adapted, adaptedService)
+		}
+	}
+
+	report, err := GetPollenReport(ctx, adapted, zipcode)
+	if err != nil {
+		return Forecast{Kind: kind}, err
+	}
+
+	forecast := ForecastFromReport(report)
+	forecast.Kind = kind
+
+	return forecast, nil
+}
+
+// GetForecasts gets each kind of forecast in parallel.  A forecast that fails
+// has its Error set -- an error is only returned if every forecast fails
+func GetForecasts(ctx context.Context, services []PollenService, kinds []ForecastKind, zipcode string) (map[ForecastKind]Forecast, error) {
+	retval := map[ForecastKind]Forecast{}
+	errs := make([]error, len(kinds))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for index, kind := range kinds {
+		wg.Add(1)
+		go func(i int, k ForecastKind) {
+			defer wg.Done()
+
+			forecast, err := GetForecast(ctx, services, k, zipcode)
+			if err != nil {
+				forecast.Error = err.Error()
+			}
+
+			mu.Lock()
+			retval[k] = forecast
+			errs[i] = err
+			mu.Unlock()
+		}(index, kind)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return retval, nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return retval, errs[0]
+	}
+
+	return retval, nil
+}
+
+// supportsKind returns true if the service supports the kind of forecast
+func supportsKind(service ForecastService, kind ForecastKind) bool {
+	for _, supported := range service.ForecastKinds() {
+		if supported == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// kindService adapts a single kind of forecast from a ForecastService to a PollenService
+type kindService struct {
+	forecaster ForecastService
+	name       string
+	kind       ForecastKind
+}
+
+// Name returns the name of the adapted service
+func (s kindService) Name() string {
+	return s.name
+}
+
+// GetPollenReport gets the forecast and returns it as a report
+func (s kindService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	forecast, err := s.forecaster.GetForecast(ctx, s.kind, zipcode)
+	if err != nil {
+		return PollenReport{}, err
+	}
+
+	retval := PollenReport{
+		Location:         forecast.Location,
+		Zipcode:          zipcode,
+		ReportingService: forecast.Service,
+		Days:             forecast.Days,
+	}
+
+	for _, day := range forecast.Days {
+		retval.Data = append(retval.Data, day.Index)
+	}
+
+	return retval, nil
+}
@@ -0,0 +1,161 @@
+package data_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+const (
+	pollencomAsthmaPath = "/api/forecast/extended/asthma/30019"
+	pollencomColdPath   = "/api/forecast/extended/cold/30019"
+)
+
+func TestParseForecastKind_ReturnsKindByName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected data.ForecastKind
+		wantErr  bool
+	}{
+		{"pollen", data.KindPollen, false},
+		{" Asthma ", data.KindAsthma, false},
+		{"coldflu", data.KindColdFlu, false},
+		{"cold-flu", data.KindColdFlu, false},
+		{"hayfever", "", true},
+	}
+
+	for _, tt := range tests {
+		kind, err := data.ParseForecastKind(tt.name)
+
+		if (err != nil) != tt.wantErr || kind != tt.expected {
+			t.Errorf("ParseForecastKind(%q) = %q, %v -- expected %q", tt.name, kind, err, tt.expected)
+		}
+	}
+}
+
+func TestPollencom_GetForecast_ReturnsAsthmaForecast(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomAsthmaPath: {file: "pollencom/asthma_30019.json"},
+	})
+	defer server.Close()
+
+	service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	forecast, err := service.GetForecast(ctx, data.KindAsthma, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetForecast: %v", err)
+	}
+
+	if forecast.Kind != data.KindAsthma || forecast.Location != "DACULA, GA" || len(forecast.Days) != 5 {
+		t.Fatalf("Unexpected forecast: %+v", forecast)
+	}
+
+	first := forecast.Days[0]
+	if first.Date != "2019-04-18" || first.Index != 6.2 || first.Category != data.CategoryMedium || first.Scale.Name != data.PollencomAsthmaScale.Name {
+		t.Errorf("Unexpected first forecast day: %+v", first)
+	}
+}
+
+func TestPollencom_GetForecast_UnknownZip_HasNoLocation(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomAsthmaPath: {file: "pollencom/unknown_zip.json"},
+	})
+	defer server.Close()
+
+	service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	forecast, err := service.GetForecast(ctx, data.KindAsthma, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetForecast: %v", err)
+	}
+
+	if forecast.Location != "" || len(forecast.Days) != 0 {
+		t.Errorf("Expected an empty forecast without a location, but got %+v", forecast)
+	}
+}
+
+func TestPollencom_GetForecast_StartsWithToday(t *testing.T) {
+	//	Arrange -- the forecast still has yesterday
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomAsthmaPath: {file: "pollencom/forecast_trimmed.json"},
+	})
+	defer server.Close()
+
+	service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	forecast, err := service.GetForecast(ctx, data.KindAsthma, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetForecast: %v", err)
+	}
+
+	if len(forecast.Days) != 1 || forecast.Days[0].Date != "2019-04-18" || forecast.Days[0].Index != 9.1 {
+		t.Errorf("Expected just today's forecast, but got %+v", forecast.Days)
+	}
+}
+
+func TestGetForecasts_ReturnsEachKind(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomAsthmaPath: {file: "pollencom/asthma_30019.json"},
+		pollencomColdPath:   {status: http.StatusServiceUnavailable},
+	})
+	defer server.Close()
+
+	services := []data.PollenService{
+		data.NasacortService{Client: server.Client(), BaseURL: server.URL},
+		data.PollencomService{Client: server.Client(), BaseURL: server.URL},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	forecasts, err := data.GetForecasts(ctx, services, []data.ForecastKind{data.KindAsthma, data.KindColdFlu}, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error when at least one forecast succeeds, but got %v", err)
+	}
+
+	if asthma := forecasts[data.KindAsthma]; asthma.Error != "" || asthma.Service != "Pollen.com" || len(asthma.Days) != 5 {
+		t.Errorf("Unexpected asthma forecast: %+v", asthma)
+	}
+
+	if cold := forecasts[data.KindColdFlu]; cold.Kind != data.KindColdFlu || cold.Error == "" {
+		t.Errorf("Expected the cold & flu forecast to have an error: %+v", cold)
+	}
+}
+
+func TestGetForecast_NoServiceSupportsKind_ReturnsError(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{data.NasacortService{}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := data.GetForecast(ctx, services, data.KindAsthma, "30019")
+
+	//	Assert
+	if _, ok := err.(*data.MultiProviderError); !ok {
+		t.Errorf("Expected a *data.MultiProviderError, but got %T (%v)", err, err)
+	}
+}
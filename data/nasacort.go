@@ -4,16 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
-	"golang.org/x/net/context/ctxhttp"
 )
 
+// NasacortBaseURL is the default base url for the Nasacort API
+const NasacortBaseURL = "https://www.nasacort.com"
+
+// NasacortScale is the native scale for the Nasacort pollen index
+var NasacortScale = IndexScale{
+	Name:       "Nasacort",
+	Min:        0,
+	Max:        12,
+	Units:      "index",
+	Thresholds: []float64{2.5, 4.9, 7.3, 9.7},
+}
+
 // NasacortService is a pollen service for Zyrtec formatted data
-type NasacortService struct{}
+type NasacortService struct {
+	Client  *http.Client // The HTTP client to use (optional -- defaults to an X-Ray instrumented client)
+	BaseURL string       // The base url for the API (optional -- defaults to NasacortBaseURL)
+}
 
 // NasacortResponse is the native service return format
 type NasacortResponse struct {
@@ -31,6 +47,16 @@ type NasacortResponse struct {
 	} `json:"response"`
 }
 
+// Name returns the name of the service
+func (s NasacortService) Name() string {
+	return "Nasacort"
+}
+
+// Scale returns the native scale for the service
+func (s NasacortService) Scale() IndexScale {
+	return NasacortScale
+}
+
 // GetPollenReport gets the pollen report
 func (s NasacortService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
 	//	Start the service segment
@@ -39,57 +65,71 @@ func (s NasacortService) GetPollenReport(ctx context.Context, zipcode string) (P
 	//	Our return value
 	retval := PollenReport{}
 
-	//	Format the url:
-	apiurl := "https://www.nasacort.com/wp-json/pollen/get/"
-
-	resp, err := ctxhttp.PostForm(ctx, xray.Client(nil), apiurl, url.Values{
-		"zipcode": {zipcode},
-	})
-
+	//	Call the API:
+	payload, err := s.post(ctx, zipcode)
 	if err != nil {
 		seg.AddError(err)
-		apperr := fmt.Errorf("There was a problem calling Nasacort API: %s", err)
-		return retval, apperr
-	}
-	defer resp.Body.Close()
-
-	//	If the HTTP status code indicates an error, report it and get out
-	if resp.StatusCode >= 400 {
-		apperr := fmt.Errorf("There was an error getting information from Nasacort API: %s", resp.Status)
-		return retval, apperr
+		return retval, err
 	}
 
 	//	Decode the return object
 	serviceResponse := NasacortResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&serviceResponse)
+	err = json.Unmarshal(payload.Body, &serviceResponse)
 	if err != nil {
 		seg.AddError(err)
-		apperr := fmt.Errorf("There was a problem decoding the response from Nasacort API: %s", err)
+		apperr := &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureDecode,
+			Err:     fmt.Errorf("There was a problem decoding the response from Nasacort API: %s", err),
+		}
 		return retval, apperr
 	}
 
+	//	Nasacort answers unknown zipcodes with an error status (and blank indices)
+	if serviceResponse.Response.Status != "success" {
+		err := &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureInsufficientData,
+			Err:     fmt.Errorf("Nasacort doesn't have pollen data for %s (status '%s')", zipcode, serviceResponse.Response.Status),
+		}
+		seg.AddError(err)
+		return retval, err
+	}
+
 	//	Parse the data items:
 	dataitems := []float64{}
-	parsedToday, _ := strconv.ParseFloat(serviceResponse.Response.Today, 64)
-	dataitems = append(dataitems, parsedToday)
-
-	parsedTomorrow, _ := strconv.ParseFloat(serviceResponse.Response.Tomorrow, 64)
-	dataitems = append(dataitems, parsedTomorrow)
-
-	parsedAfterTomorrrow, _ := strconv.ParseFloat(serviceResponse.Response.AfterTomorrow, 64)
-	dataitems = append(dataitems, parsedAfterTomorrrow)
+	for _, index := range []string{
+		serviceResponse.Response.Today,
+		serviceResponse.Response.Tomorrow,
+		serviceResponse.Response.AfterTomorrow,
+		serviceResponse.Response.Day4,
+	} {
+		parsed, err := strconv.ParseFloat(index, 64)
+		if err != nil {
+			err := &ProviderError{
+				Service: s.Name(),
+				Reason:  FailureInsufficientData,
+				Err:     fmt.Errorf("Nasacort returned an index that isn't a number ('%s') for %s", index, zipcode),
+			}
+			seg.AddError(err)
+			return retval, err
+		}
+		dataitems = append(dataitems, parsed)
+	}
 
-	parsedDay4, _ := strconv.ParseFloat(serviceResponse.Response.Day4, 64)
-	dataitems = append(dataitems, parsedDay4)
+	//	Nasacort doesn't tell us the forecast date, so start with today in the location's timezone
+	today := time.Now().In(stateLocation(serviceResponse.Response.State))
 
 	//	Set the properties in the return object:
 	retval = PollenReport{
-		ReportingService:  "Nasacort",
+		ReportingService:  s.Name(),
 		PredominantPollen: serviceResponse.Response.Source,
 		Zipcode:           zipcode,
 		Location:          fmt.Sprintf("%s, %s", serviceResponse.Response.City, serviceResponse.Response.State),
 		StartDate:         time.Now(),
 		Data:              dataitems,
+		Days:              newForecastDays(today, dataitems, NasacortScale),
+		Allergens:         ParseAllergens(serviceResponse.Response.Source),
 	}
 
 	xray.AddMetadata(ctx, "NasacortResult", retval)
@@ -99,3 +139,31 @@ func (s NasacortService) GetPollenReport(ctx context.Context, zipcode string) (P
 
 	return retval, nil
 }
+
+// GetRawReport gets the Nasacort API response as-is
+func (s NasacortService) GetRawReport(ctx context.Context, zipcode string) ([]RawPayload, error) {
+	payload, err := s.post(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return []RawPayload{payload}, nil
+}
+
+// post calls the Nasacort API for the zipcode and returns the response body
+func (s NasacortService) post(ctx context.Context, zipcode string) (RawPayload, error) {
+	//	Format the url:
+	apiurl := fmt.Sprintf("%s/wp-json/pollen/get/", baseURL(s.BaseURL, NasacortBaseURL))
+
+	form := url.Values{"zipcode": {zipcode}}
+	req, _ := http.NewRequest("POST", apiurl, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	payload, err := doPayload(ctx, s.Client, s.Name(), "Nasacort", req, nil)
+	if err != nil {
+		return RawPayload{}, err
+	}
+
+	payload.Name = "pollen"
+	return payload, nil
+}
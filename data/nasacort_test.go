@@ -2,15 +2,26 @@ package data_test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/danesparza/pollen/data"
 )
 
+const nasacortPath = "/wp-json/pollen/get/"
+
 func TestNasacort_GetPollenReport_ReturnsValidData(t *testing.T) {
 	//	Arrange
-	service := data.NasacortService{}
+	server := newFixtureServer(t, map[string]fixture{
+		nasacortPath: {file: "nasacort/30019.json"},
+	})
+	defer server.Close()
+
+	service := data.NasacortService{Client: server.Client(), BaseURL: server.URL}
 	zipcode := "30019"
 	ctx := context.Background()
 	ctx, seg := xray.BeginSegment(ctx, "unit-test")
@@ -21,9 +32,143 @@ func TestNasacort_GetPollenReport_ReturnsValidData(t *testing.T) {
 
 	//	Assert
 	if err != nil {
-		t.Errorf("Error calling GetPollenReport: %v", err)
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	if expected := []float64{10.2, 1, 7.9, 10}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected data %v, but got %v", expected, response.Data)
+	}
+
+	if len(response.Days) != len(response.Data) {
+		t.Fatalf("Expected a forecast day for each datapoint, but got %d", len(response.Days))
+	}
+
+	today := time.Now().In(mustLoadLocation(t, "America/New_York")).Format(data.DateFormat)
+	if response.Days[0].Date != today || response.Days[0].Category != data.CategoryHigh || response.Days[1].Category != data.CategoryLow {
+		t.Errorf("Unexpected first forecast days: %+v", response.Days[:2])
+	}
+
+	if response.Location != "DACULA, GA" {
+		t.Errorf("Expected location 'DACULA, GA', but got '%s'", response.Location)
+	}
+
+	if response.PredominantPollen != "Oak, Birch and Sycamore." {
+		t.Errorf("Unexpected predominant pollen: '%s'", response.PredominantPollen)
+	}
+
+	expectedAllergens := []data.Allergen{
+		{Name: "Oak", Genus: "Quercus", PlantType: data.PlantTree},
+		{Name: "Birch", Genus: "Betula", PlantType: data.PlantTree},
+		{Name: "Sycamore", Genus: "Platanus", PlantType: data.PlantTree},
+	}
+	if !reflect.DeepEqual(response.Allergens, expectedAllergens) {
+		t.Errorf("Expected allergens %+v, but got %+v", expectedAllergens, response.Allergens)
+	}
+
+	if response.ReportingService != "Nasacort" || response.Zipcode != zipcode {
+		t.Errorf("Unexpected service or zipcode: %s / %s", response.ReportingService, response.Zipcode)
 	}
 
 	t.Logf("Returned object: %+v", response)
 
 }
+
+func TestNasacort_GetPollenReport_PostsZipcode(t *testing.T) {
+	//	Arrange
+	posted := ""
+	body := loadFixture(t, "nasacort/30019.json")
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" || req.URL.Path != nasacortPath {
+			http.NotFound(rw, req)
+			return
+		}
+
+		posted = req.PostFormValue("zipcode")
+		rw.Write(body)
+	}))
+	defer server.Close()
+
+	service := data.NasacortService{Client: server.Client(), BaseURL: server.URL + "/"}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	if posted != "30019" {
+		t.Errorf("Expected the zipcode 30019 to be posted, but got '%s'", posted)
+	}
+}
+
+func TestNasacort_GetPollenReport_UnknownZip_ReturnsInsufficientData(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		nasacortPath: {file: "nasacort/unknown_zip.json"},
+	})
+	defer server.Close()
+
+	service := data.NasacortService{Client: server.Client(), BaseURL: server.URL}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := service.GetPollenReport(ctx, "00000")
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailureInsufficientData || perr.Service != "Nasacort" {
+		t.Errorf("Expected an insufficient data error from Nasacort, but got %T (%v)", err, err)
+	}
+
+	if len(response.Data) != 0 {
+		t.Errorf("Expected no indices for the unknown zipcode, but got %v", response.Data)
+	}
+}
+
+func TestNasacort_GetPollenReport_Failures(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseURL    string
+		route      fixture
+		reason     data.FailureReason
+		statusCode int
+	}{
+		{"server error", "", fixture{status: http.StatusInternalServerError, file: "nasacort/malformed.html"}, data.FailureHTTPStatus, http.StatusInternalServerError},
+		{"html instead of json", "", fixture{file: "nasacort/malformed.html"}, data.FailureDecode, 0},
+		{"connection refused", newClosedServerURL(), fixture{}, data.FailureTransport, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//	Arrange
+			server := newFixtureServer(t, map[string]fixture{nasacortPath: tt.route})
+			defer server.Close()
+
+			url := server.URL
+			if tt.baseURL != "" {
+				url = tt.baseURL
+			}
+
+			service := data.NasacortService{Client: server.Client(), BaseURL: url}
+			ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+			defer seg.Close(nil)
+
+			//	Act
+			_, err := service.GetPollenReport(ctx, "30019")
+
+			//	Assert
+			perr, ok := err.(*data.ProviderError)
+			if !ok {
+				t.Fatalf("Expected a *data.ProviderError, but got %T (%v)", err, err)
+			}
+
+			if perr.Reason != tt.reason || perr.StatusCode != tt.statusCode {
+				t.Errorf("Expected %s/%d, but got %s/%d", tt.reason, tt.statusCode, perr.Reason, perr.StatusCode)
+			}
+		})
+	}
+}
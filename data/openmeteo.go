@@ -0,0 +1,354 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// OpenMeteoBaseURL is the default base url for the Open-Meteo air quality API
+const OpenMeteoBaseURL = "https://air-quality-api.open-meteo.com"
+
+// OpenMeteoForecastDays is how many days of forecast are requested from Open-Meteo
+const OpenMeteoForecastDays = 4
+
+// OpenMeteoCountries are the ISO country codes Open-Meteo is used for.  Its
+// pollen comes from the CAMS European air quality forecast, but postal codes
+// are handled like zipcodes (5 digits), so only German postal codes are
+// supported
+var OpenMeteoCountries = []string{"DE"}
+
+// OpenMeteoScale is the native scale for Open-Meteo: the daily peak of the
+// total pollen concentration
+var OpenMeteoScale = IndexScale{
+	Name:       "Open-Meteo",
+	Min:        0,
+	Max:        500,
+	Units:      "grains/m³",
+	Thresholds: []float64{10, 50, 100, 250},
+}
+
+// openMeteoSpecies are the pollen species Open-Meteo reports, with their
+// hourly variable names and the canonical allergen for each
+var openMeteoSpecies = []struct {
+	variable string
+	allergen string
+}{
+	{"alder_pollen", "Alder"},
+	{"birch_pollen", "Birch"},
+	{"grass_pollen", "Grass"},
+	{"mugwort_pollen", "Mugwort"},
+	{"olive_pollen", "Olive"},
+	{"ragweed_pollen", "Ragweed"},
+}
+
+// OpenMeteoService is a pollen service for Open-Meteo air quality data.  It
+// needs coordinates, so postal codes are resolved with its Geocoder first.
+// Open-Meteo only has pollen for Europe, and only German postal codes are
+// supported (see OpenMeteoCountries)
+type OpenMeteoService struct {
+	Client   *http.Client // The HTTP client to use (optional -- defaults to an X-Ray instrumented client)
+	BaseURL  string       // The base url for the API (optional -- defaults to OpenMeteoBaseURL)
+	Country  string       // The ISO country code the postal codes are in (optional -- defaults to ZipcodeCountry)
+	Geocoder Geocoder     // How postal codes are resolved to coordinates (optional -- defaults to an OpenMeteoGeocoder for Country with the same client)
+}
+
+// OpenMeteoResponse is the native service return format.  Hourly has a 'time'
+// list and a list of concentrations (which can be null) for each variable
+type OpenMeteoResponse struct {
+	Latitude    float64                    `json:"latitude"`
+	Longitude   float64                    `json:"longitude"`
+	Timezone    string                     `json:"timezone"`
+	HourlyUnits map[string]string          `json:"hourly_units"`
+	Hourly      map[string]json.RawMessage `json:"hourly"`
+}
+
+// SpeciesConcentration is the pollen concentration for a single species on a day
+type SpeciesConcentration struct {
+	Allergen
+	Peak  float64 `json:"peak"`  // The highest hourly concentration
+	Mean  float64 `json:"mean"`  // The mean hourly concentration
+	Units string  `json:"units"` // The units of the concentrations
+}
+
+// Name returns the name of the service
+func (s OpenMeteoService) Name() string {
+	return "Open-Meteo"
+}
+
+// Scale returns the native scale for the service
+func (s OpenMeteoService) Scale() IndexScale {
+	return OpenMeteoScale
+}
+
+// CountryCode returns the ISO country code the postal codes are in
+func (s OpenMeteoService) CountryCode() string {
+	return ProviderSettings{Country: s.Country}.country()
+}
+
+// GetPollenReport gets the pollen report
+func (s OpenMeteoService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "openmeteo-service")
+
+	//	Our return value
+	retval := PollenReport{}
+
+	coordinates, payload, err := s.fetch(ctx, zipcode)
+	if err != nil {
+		seg.AddError(err)
+		return retval, err
+	}
+
+	//	Decode the return object
+	serviceResponse := OpenMeteoResponse{}
+	if err := json.Unmarshal(payload.Body, &serviceResponse); err != nil {
+		seg.AddError(err)
+		return retval, &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureDecode,
+			Err:     fmt.Errorf("There was a problem decoding the response from the Open-Meteo air quality API: %s", err),
+		}
+	}
+
+	days, err := openMeteoDays(serviceResponse)
+	if err != nil {
+		seg.AddError(err)
+		return retval, &ProviderError{Service: s.Name(), Reason: FailureDecode, Err: err}
+	}
+
+	//	Open-Meteo only has pollen where (and when) it's modeled
+	if len(days) == 0 {
+		err := &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureInsufficientData,
+			Err:     fmt.Errorf("Open-Meteo doesn't have pollen data for %s", zipcode),
+		}
+		seg.AddError(err)
+		return retval, err
+	}
+
+	//	Parse the data items:
+	dataitems := []float64{}
+	for _, day := range days {
+		dataitems = append(dataitems, day.Index)
+	}
+
+	//	The predominant pollen is today's species, most concentrated first
+	allergens := []Allergen{}
+	predomPollens := []string{}
+	for _, species := range days[0].Species {
+		if species.Peak > 0 {
+			allergens = append(allergens, species.Allergen)
+			predomPollens = append(predomPollens, species.Name)
+		}
+	}
+
+	//	Set the properties in the return object:
+	retval = PollenReport{
+		ReportingService:  s.Name(),
+		PredominantPollen: strings.Join(predomPollens, ", "),
+		Zipcode:           zipcode,
+		Location:          coordinates.Location,
+		StartDate:         time.Now(),
+		Data:              dataitems,
+		Days:              days,
+		Allergens:         allergens,
+	}
+
+	xray.AddMetadata(ctx, "OpenMeteoResult", retval)
+
+	// Close the segment
+	seg.Close(nil)
+
+	return retval, nil
+}
+
+// GetRawReport gets the air quality API response the pollen report is built from
+func (s OpenMeteoService) GetRawReport(ctx context.Context, zipcode string) ([]RawPayload, error) {
+	_, payload, err := s.fetch(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return []RawPayload{payload}, nil
+}
+
+// fetch resolves the zipcode's coordinates and calls the air quality API for them
+func (s OpenMeteoService) fetch(ctx context.Context, zipcode string) (Coordinates, RawPayload, error) {
+	geocoder := s.Geocoder
+	if geocoder == nil {
+		geocoder = OpenMeteoGeocoder{Client: s.Client, Country: s.Country}
+	}
+
+	coordinates, err := geocoder.Geocode(ctx, zipcode)
+	if err != nil {
+		if _, ok := err.(*ProviderError); !ok {
+			err = &ProviderError{Service: s.Name(), Reason: FailureInsufficientData, Err: err}
+		}
+		return Coordinates{}, RawPayload{}, err
+	}
+
+	variables := []string{}
+	for _, species := range openMeteoSpecies {
+		variables = append(variables, species.variable)
+	}
+
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%.4f", coordinates.Latitude))
+	query.Set("longitude", fmt.Sprintf("%.4f", coordinates.Longitude))
+	query.Set("hourly", strings.Join(variables, ","))
+	query.Set("timezone", "auto")
+	query.Set("forecast_days", fmt.Sprintf("%d", OpenMeteoForecastDays))
+	apiurl := fmt.Sprintf("%s/v1/air-quality?%s", baseURL(s.BaseURL, OpenMeteoBaseURL), query.Encode())
+
+	payload, err := fetchPayload(ctx, s.Client, s.Name(), "Open-Meteo air quality", apiurl)
+	if err != nil {
+		return Coordinates{}, RawPayload{}, err
+	}
+
+	return coordinates, payload, nil
+}
+
+// openMeteoDays rolls the hourly concentrations up into a forecast for each
+// local calendar day: the index is the day's peak total concentration, the
+// plant sub-indices are the peaks for trees, grasses and weeds, and each
+// species has its peak and mean.  Days without any data are left out
+func openMeteoDays(response OpenMeteoResponse) ([]ForecastDay, error) {
+	times := []string{}
+	if err := json.Unmarshal(response.Hourly["time"], &times); err != nil {
+		return nil, fmt.Errorf("There was a problem decoding the hourly times from the Open-Meteo air quality API: %s", err)
+	}
+
+	concentrations := make([][]*float64, len(openMeteoSpecies))
+	for index, species := range openMeteoSpecies {
+		if raw, ok := response.Hourly[species.variable]; ok {
+			if err := json.Unmarshal(raw, &concentrations[index]); err != nil {
+				return nil, fmt.Errorf("There was a problem decoding %s from the Open-Meteo air quality API: %s", species.variable, err)
+			}
+		}
+	}
+
+	//	The running totals for each day
+	type dayTotals struct {
+		date                                string
+		peak, tree, grass, weed             float64 // The peak hourly totals
+		hasData, hasTree, hasGrass, hasWeed bool
+		speciesPeak, speciesSum             []float64
+		speciesHours                        []int // How many hours each species has data for
+	}
+
+	days := []*dayTotals{}
+	for hour, timestamp := range times {
+		if len(timestamp) < len(DateFormat) {
+			continue
+		}
+		date := timestamp[:len(DateFormat)]
+
+		if len(days) == 0 || days[len(days)-1].date != date {
+			days = append(days, &dayTotals{
+				date:         date,
+				speciesPeak:  make([]float64, len(openMeteoSpecies)),
+				speciesSum:   make([]float64, len(openMeteoSpecies)),
+				speciesHours: make([]int, len(openMeteoSpecies)),
+			})
+		}
+		day := days[len(days)-1]
+
+		total, tree, grass, weed := 0.0, 0.0, 0.0, 0.0
+		hourHasData := false
+		for index, species := range openMeteoSpecies {
+			if hour >= len(concentrations[index]) || concentrations[index][hour] == nil {
+				continue
+			}
+
+			value := *concentrations[index][hour]
+			hourHasData = true
+			total += value
+			day.speciesSum[index] += value
+			day.speciesHours[index]++
+			day.speciesPeak[index] = math.Max(day.speciesPeak[index], value)
+
+			switch LookupAllergen(species.allergen).PlantType {
+			case PlantTree:
+				tree += value
+				day.hasTree = true
+			case PlantGrass:
+				grass += value
+				day.hasGrass = true
+			default:
+				weed += value
+				day.hasWeed = true
+			}
+		}
+
+		if !hourHasData {
+			continue
+		}
+
+		day.hasData = true
+		day.peak = math.Max(day.peak, total)
+		day.tree = math.Max(day.tree, tree)
+		day.grass = math.Max(day.grass, grass)
+		day.weed = math.Max(day.weed, weed)
+	}
+
+	retval := []ForecastDay{}
+	for _, day := range days {
+		if !day.hasData {
+			continue
+		}
+
+		forecast := newForecastDay(day.date, roundConcentration(day.peak), OpenMeteoScale)
+		forecast.Plants = &PlantIndices{}
+		if day.hasTree {
+			forecast.Plants.Tree = floatPointer(roundConcentration(day.tree))
+		}
+		if day.hasGrass {
+			forecast.Plants.Grass = floatPointer(roundConcentration(day.grass))
+		}
+		if day.hasWeed {
+			forecast.Plants.Weed = floatPointer(roundConcentration(day.weed))
+		}
+
+		for index, species := range openMeteoSpecies {
+			if day.speciesHours[index] == 0 {
+				continue
+			}
+
+			forecast.Species = append(forecast.Species, SpeciesConcentration{
+				Allergen: LookupAllergen(species.allergen),
+				Peak:     roundConcentration(day.speciesPeak[index]),
+				Mean:     roundConcentration(day.speciesSum[index] / float64(day.speciesHours[index])),
+				Units:    OpenMeteoScale.Units,
+			})
+		}
+
+		//	Most concentrated first
+		sort.SliceStable(forecast.Species, func(i, j int) bool {
+			return forecast.Species[i].Peak > forecast.Species[j].Peak
+		})
+
+		retval = append(retval, forecast)
+	}
+
+	return retval, nil
+}
+
+// roundConcentration rounds a concentration to one decimal place
+func roundConcentration(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// floatPointer returns a pointer to the value
+func floatPointer(value float64) *float64 {
+	return &value
+}
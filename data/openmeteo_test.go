@@ -0,0 +1,147 @@
+package data_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+const (
+	openMeteoGeocodePath    = "/v1/search"
+	openMeteoAirQualityPath = "/v1/air-quality"
+)
+
+func TestOpenMeteo_GetPollenReport_ReturnsValidData(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		openMeteoGeocodePath:    {file: "openmeteo/geocode_10115.json"},
+		openMeteoAirQualityPath: {file: "openmeteo/air_quality_10115.json"},
+	})
+	defer server.Close()
+
+	service := data.OpenMeteoService{
+		Client:   server.Client(),
+		BaseURL:  server.URL,
+		Geocoder: data.OpenMeteoGeocoder{Client: server.Client(), BaseURL: server.URL, Country: "DE"},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := service.GetPollenReport(ctx, "10115")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	//	The daily peak of the total concentration, including the partial last day
+	if expected := []float64{96, 133, 50, 19}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected data %v, but got %v", expected, response.Data)
+	}
+
+	if response.Location != "Berlin Mitte, DE" {
+		t.Errorf("Expected location 'Berlin Mitte, DE', but got '%s'", response.Location)
+	}
+
+	if len(response.Days) != 4 || response.Days[0].Date != "2019-04-18" || response.Days[0].Category != data.CategoryMedium {
+		t.Fatalf("Expected 4 forecast days starting on 2019-04-18, but got %+v", response.Days)
+	}
+
+	today := response.Days[0]
+	if today.Plants == nil || today.Plants.Tree == nil || *today.Plants.Tree != 84 || today.Plants.Grass == nil || *today.Plants.Grass != 12 {
+		t.Errorf("Expected tree and grass sub-indices, but got %+v", today.Plants)
+	}
+
+	birch := today.Species[0]
+	if birch.Name != "Birch" || birch.Genus != "Betula" || birch.Peak != 80 || birch.Mean != 25.3 || birch.Units != "grains/m³" {
+		t.Errorf("Expected birch to be the most concentrated species, but got %+v", birch)
+	}
+
+	if len(today.Species) != 6 {
+		t.Errorf("Expected all 6 species, but got %+v", today.Species)
+	}
+
+	if response.PredominantPollen != "Birch, Grass, Alder" {
+		t.Errorf("Expected the species with pollen, most concentrated first, but got '%s'", response.PredominantPollen)
+	}
+}
+
+func TestOpenMeteo_GetPollenReport_NoPollenData_ReturnsInsufficientData(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		openMeteoAirQualityPath: {file: "openmeteo/air_quality_no_pollen.json"},
+	})
+	defer server.Close()
+
+	service := data.OpenMeteoService{
+		Client:   server.Client(),
+		BaseURL:  server.URL,
+		Geocoder: data.StaticGeocoder{"30019": {Latitude: 33.98872, Longitude: -83.89796, Location: "Dacula, GA"}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailureInsufficientData {
+		t.Errorf("Expected an insufficient data error, but got %T (%v)", err, err)
+	}
+}
+
+func TestOpenMeteo_GetPollenReport_UnknownZipcode_ReturnsError(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		openMeteoGeocodePath:    {file: "openmeteo/geocode_unknown.json"},
+		openMeteoAirQualityPath: {file: "openmeteo/air_quality_10115.json"},
+	})
+	defer server.Close()
+
+	service := data.OpenMeteoService{
+		Client:   server.Client(),
+		BaseURL:  server.URL,
+		Geocoder: data.OpenMeteoGeocoder{Client: server.Client(), BaseURL: server.URL},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "99999")
+
+	//	Assert
+	perr, ok := err.(*data.ProviderError)
+	if !ok || perr.Reason != data.FailureInsufficientData || perr.Service != "Open-Meteo" {
+		t.Errorf("Expected an insufficient data error from Open-Meteo, but got %T (%v)", err, err)
+	}
+}
+
+func TestOpenMeteo_GetPollenReport_ServerError_ReturnsHTTPStatus(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		openMeteoAirQualityPath: {status: http.StatusBadGateway},
+	})
+	defer server.Close()
+
+	service := data.OpenMeteoService{
+		Client:   server.Client(),
+		BaseURL:  server.URL,
+		Geocoder: data.StaticGeocoder{"30019": {Latitude: 33.98872, Longitude: -83.89796}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019-1234")
+
+	//	Assert
+	perr, ok := err.(*data.ProviderError)
+	if !ok || perr.Reason != data.FailureHTTPStatus || perr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected a 502 status error, but got %T (%v)", err, err)
+	}
+}
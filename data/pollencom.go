@@ -8,13 +8,61 @@ import (
 	"strings"
 	"time"
 
-	"golang.org/x/net/context/ctxhttp"
-
 	"github.com/aws/aws-xray-sdk-go/xray"
 )
 
+// PollencomBaseURL is the default base url for the Pollen.com API
+const PollencomBaseURL = "https://www.pollen.com"
+
+// PollencomScale is the native scale for the Pollen.com pollen index
+var PollencomScale = IndexScale{
+	Name:       "Pollen.com",
+	Min:        0,
+	Max:        12,
+	Units:      "index",
+	Thresholds: []float64{2.5, 4.9, 7.3, 9.7},
+}
+
+// PollencomAsthmaScale is the native scale for the Pollen.com asthma index
+var PollencomAsthmaScale = IndexScale{
+	Name:       "Pollen.com asthma",
+	Min:        0,
+	Max:        12,
+	Units:      "index",
+	Thresholds: []float64{2.5, 4.9, 7.3, 9.7},
+}
+
+// PollencomColdFluScale is the native scale for the Pollen.com cold & flu index
+var PollencomColdFluScale = IndexScale{
+	Name:       "Pollen.com cold & flu",
+	Min:        0,
+	Max:        12,
+	Units:      "index",
+	Thresholds: []float64{2.5, 4.9, 7.3, 9.7},
+}
+
+// pollencomKinds maps each kind of forecast to its Pollen.com index name and scale
+var pollencomKinds = map[ForecastKind]struct {
+	index string
+	scale IndexScale
+}{
+	KindPollen:  {"pollen", PollencomScale},
+	KindAsthma:  {"asthma", PollencomAsthmaScale},
+	KindColdFlu: {"cold", PollencomColdFluScale},
+}
+
+// pollencomPeriodFormat is the format of the dates for each forecast period
+const pollencomPeriodFormat = "2006-01-02T15:04:05"
+
+// PollencomForecastDays is how many days of the extended forecast (starting
+// with today) are in the pollen report
+const PollencomForecastDays = 4
+
 // PollencomService is a pollen service for Pollen.com formatted data
-type PollencomService struct{}
+type PollencomService struct {
+	Client  *http.Client // The HTTP client to use (optional -- defaults to an X-Ray instrumented client)
+	BaseURL string       // The base url for the API (optional -- defaults to PollencomBaseURL)
+}
 
 // PollencomForecastResponse is the native service return format for the extended forecast (includes pollen indices)
 type PollencomForecastResponse struct {
@@ -26,6 +74,7 @@ type PollencomForecastResponse struct {
 		State   string `json:"State"`
 		Periods []struct {
 			Period string  `json:"Period"`
+			Type   string  `json:"Type"`
 			Index  float64 `json:"Index"`
 		} `json:"periods"`
 		DisplayLocation string `json:"DisplayLocation"`
@@ -55,6 +104,16 @@ type PollencomCurrentResponse struct {
 	} `json:"Location"`
 }
 
+// Name returns the name of the service
+func (s PollencomService) Name() string {
+	return "Pollen.com"
+}
+
+// Scale returns the native scale for the service
+func (s PollencomService) Scale() IndexScale {
+	return PollencomScale
+}
+
 // GetPollenReport gets the pollen report
 func (s PollencomService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
 	//	Start the service segment
@@ -63,111 +122,301 @@ func (s PollencomService) GetPollenReport(ctx context.Context, zipcode string) (
 	//	Our return value
 	retval := PollenReport{}
 
-	//	Format the extended forecast url (to get the pollen indices):
-	apiurl := fmt.Sprintf("https://www.pollen.com/api/forecast/extended/pollen/%s", zipcode)
+	//	Get the extended forecast (to get the pollen indices):
+	serviceResponse := PollencomForecastResponse{}
+	if err := s.get(ctx, "extended", "pollen", zipcode, &serviceResponse); err != nil {
+		seg.AddError(err)
+		return retval, err
+	}
 
-	req, _ := http.NewRequest("GET", apiurl, nil)
-	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/65.0.3325.146 Safari/537.36")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Referer", apiurl)
+	//	Parse the data items (today and the days after it -- however many periods there are):
+	upcoming := pollencomUpcoming(serviceResponse)
+	if len(upcoming.Location.Periods) > PollencomForecastDays {
+		upcoming.Location.Periods = upcoming.Location.Periods[:PollencomForecastDays]
+	}
 
-	resp, err := ctxhttp.Do(ctx, xray.Client(nil), req)
+	dataitems := []float64{}
+	for _, period := range upcoming.Location.Periods {
+		dataitems = append(dataitems, period.Index)
+	}
 
-	if err != nil {
+	//	Get the current conditions (to get predominant pollen):
+	serviceCurrentResponse := PollencomCurrentResponse{}
+	if err := s.get(ctx, "current", "pollen", zipcode, &serviceCurrentResponse); err != nil {
 		seg.AddError(err)
-		apperr := fmt.Errorf("There was a problem calling Pollen.com extended forecast API: %s", err)
-		return retval, apperr
+		return retval, err
 	}
-	defer resp.Body.Close()
 
-	//	If the HTTP status code indicates an error, report it and get out
-	if resp.StatusCode >= 400 {
-		apperr := fmt.Errorf("There was an error getting information from Pollen.com extended forecast API: %s", resp.Status)
-		seg.AddError(apperr)
+	//	Build the predominant pollen from today's triggers (the current conditions
+	//	also have yesterday and tomorrow):
+	predomPollens := []string{}
+	allergens := []Allergen{}
+	labels := []pollencomLabel{}
+	for _, period := range serviceCurrentResponse.Location.Periods {
+		labels = append(labels, pollencomLabel{period.Type, period.Period})
+	}
+	if today := pollencomToday(labels, serviceCurrentResponse.ForecastDate); today >= 0 {
+		for _, trigger := range serviceCurrentResponse.Location.Periods[today].Triggers {
+			predomPollens = append(predomPollens, trigger.Name)
+			allergens = append(allergens, canonicalAllergen(Allergen{
+				Name:      trigger.Name,
+				Genus:     trigger.Genus,
+				PlantType: parsePlantType(trigger.PlantType),
+			}))
+		}
+	}
+
+	predomPollen := strings.Join(predomPollens, ", ")
+
+	//	Set the properties in the return object:
+	retval = PollenReport{
+		ReportingService:  s.Name(),
+		PredominantPollen: predomPollen,
+		Zipcode:           zipcode,
+		Location:          pollencomLocation(serviceResponse.Location.City, serviceResponse.Location.State),
+		StartDate:         time.Now(),
+		Data:              dataitems,
+		Days:              pollencomForecastDays(upcoming, dataitems, PollencomScale),
+		Allergens:         allergens,
+	}
+
+	xray.AddMetadata(ctx, "PollencomResult", retval)
+
+	// Close the segment
+	seg.Close(nil)
+
+	return retval, nil
+}
+
+// ForecastKinds returns the kinds of forecasts the service supports
+func (s PollencomService) ForecastKinds() []ForecastKind {
+	return []ForecastKind{KindPollen, KindAsthma, KindColdFlu}
+}
+
+// GetForecast gets the extended forecast of the given kind
+func (s PollencomService) GetForecast(ctx context.Context, kind ForecastKind, zipcode string) (Forecast, error) {
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "pollencom-forecast")
+
+	retval := Forecast{Kind: kind, Service: s.Name()}
+
+	pollencomKind, ok := pollencomKinds[kind]
+	if !ok {
+		apperr := fmt.Errorf("Pollen.com doesn't have a %s forecast", kind)
+		seg.Close(apperr)
 		return retval, apperr
 	}
 
-	//	Decode the return object
 	serviceResponse := PollencomForecastResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&serviceResponse)
-	if err != nil {
-		seg.AddError(err)
-		apperr := fmt.Errorf("There was a problem decoding the response from Pollen.com extended forecast API: %s", err)
-		return retval, apperr
+	if err := s.get(ctx, "extended", pollencomKind.index, zipcode, &serviceResponse); err != nil {
+		seg.Close(err)
+		return retval, err
 	}
 
-	//	Parse the data items:
-	dataitems := []float64{}
+	//	Start with today (the extended forecast can still have yesterday)
+	upcoming := pollencomUpcoming(serviceResponse)
+
+	indices := []float64{}
+	for _, period := range upcoming.Location.Periods {
+		indices = append(indices, period.Index)
+	}
+
+	retval.Location = pollencomLocation(serviceResponse.Location.City, serviceResponse.Location.State)
+	retval.Days = pollencomForecastDays(upcoming, indices, pollencomKind.scale)
+
+	xray.AddMetadata(ctx, "PollencomForecast", retval)
+
+	// Close the segment
+	seg.Close(nil)
+
+	return retval, nil
+}
 
-	if len(serviceResponse.Location.Periods) > 0 {
-		parsedToday := serviceResponse.Location.Periods[0].Index
-		dataitems = append(dataitems, parsedToday)
+// GetPollenHistory gets the observed pollen for each day from start to end.
+// Pollen.com only publishes the last 30 days or so
+func (s PollencomService) GetPollenHistory(ctx context.Context, zipcode string, start, end time.Time) (History, error) {
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "pollencom-history")
 
-		parsedTomorrow := serviceResponse.Location.Periods[1].Index
-		dataitems = append(dataitems, parsedTomorrow)
+	retval := History{
+		Start:   start.Format(DateFormat),
+		End:     end.Format(DateFormat),
+		Service: s.Name(),
+	}
 
-		parsedAfterTomorrrow := serviceResponse.Location.Periods[2].Index
-		dataitems = append(dataitems, parsedAfterTomorrrow)
+	serviceResponse := PollencomForecastResponse{}
+	if err := s.get(ctx, "historic", "pollen", zipcode, &serviceResponse); err != nil {
+		seg.Close(err)
+		return retval, err
+	}
 
-		parsedDay4 := serviceResponse.Location.Periods[3].Index
-		dataitems = append(dataitems, parsedDay4)
+	indices := []float64{}
+	for _, period := range serviceResponse.Location.Periods {
+		indices = append(indices, period.Index)
 	}
 
-	//	Format the current conditions url (to get predominant pollen):
-	currentapiurl := fmt.Sprintf("https://www.pollen.com/api/forecast/current/pollen/%s", zipcode)
+	retval.Location = pollencomLocation(serviceResponse.Location.City, serviceResponse.Location.State)
+	retval.Days = filterDays(pollencomForecastDays(serviceResponse, indices, PollencomScale), start, end)
 
-	currreq, _ := http.NewRequest("GET", currentapiurl, nil)
-	currreq.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/65.0.3325.146 Safari/537.36")
-	currreq.Header.Add("Accept", "application/json")
-	currreq.Header.Add("Referer", currentapiurl)
+	xray.AddMetadata(ctx, "PollencomHistory", retval)
 
-	currresp, err := ctxhttp.Do(ctx, xray.Client(nil), currreq)
+	// Close the segment
+	seg.Close(nil)
 
-	if err != nil {
-		seg.AddError(err)
-		apperr := fmt.Errorf("There was a problem calling Pollen.com current forecast API: %s", err)
-		return retval, apperr
+	return retval, nil
+}
+
+// GetRawReport gets the Pollen.com extended forecast and current conditions
+// API responses as-is
+func (s PollencomService) GetRawReport(ctx context.Context, zipcode string) ([]RawPayload, error) {
+	retval := []RawPayload{}
+	for _, forecast := range []string{"extended", "current"} {
+		payload, err := s.fetch(ctx, forecast, "pollen", zipcode)
+		if err != nil {
+			return nil, err
+		}
+		retval = append(retval, payload)
 	}
-	defer resp.Body.Close()
 
-	//	If the HTTP status code indicates an error, report it and get out
-	if currresp.StatusCode >= 400 {
-		apperr := fmt.Errorf("There was an error getting information from Pollen.com current forecast API: %s", resp.Status)
-		seg.AddError(apperr)
-		return retval, apperr
+	return retval, nil
+}
+
+// get calls the given Pollen.com forecast API ('extended', 'current' or 'historic') for the
+// index ('pollen', 'asthma' or 'cold') and zipcode and decodes the response into target
+func (s PollencomService) get(ctx context.Context, forecast, index, zipcode string, target interface{}) error {
+	payload, err := s.fetch(ctx, forecast, index, zipcode)
+	if err != nil {
+		return err
 	}
 
 	//	Decode the return object
-	serviceCurrentResponse := PollencomCurrentResponse{}
-	err = json.NewDecoder(currresp.Body).Decode(&serviceCurrentResponse)
+	if err := json.Unmarshal(payload.Body, target); err != nil {
+		return &ProviderError{
+			Service: s.Name(),
+			Reason:  FailureDecode,
+			Err:     fmt.Errorf("There was a problem decoding the response from Pollen.com %s forecast API: %s", payload.Name, err),
+		}
+	}
+
+	return nil
+}
+
+// fetch calls the Pollen.com forecast API for the index and returns the response body
+func (s PollencomService) fetch(ctx context.Context, forecast, index, zipcode string) (RawPayload, error) {
+	//	Format the url:
+	apiurl := fmt.Sprintf("%s/api/forecast/%s/%s/%s", baseURL(s.BaseURL, PollencomBaseURL), forecast, index, zipcode)
+
+	//	Describe the API in errors the same way Pollen.com does
+	if index != "pollen" {
+		forecast = fmt.Sprintf("%s %s", forecast, index)
+	}
+
+	req, _ := http.NewRequest("GET", apiurl, nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/65.0.3325.146 Safari/537.36")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Referer", apiurl)
+
+	payload, err := doPayload(ctx, s.Client, s.Name(), fmt.Sprintf("Pollen.com %s forecast", forecast), req, nil)
 	if err != nil {
-		seg.AddError(err)
-		apperr := fmt.Errorf("There was a problem decoding the response from Pollen.com current forecast API: %s", err)
-		return retval, apperr
+		return RawPayload{}, err
 	}
 
-	//	Build the predominant pollen:
-	predomPollens := []string{}
-	for _, trigger := range serviceCurrentResponse.Location.Periods[0].Triggers {
-		predomPollens = append(predomPollens, trigger.Name)
+	payload.Name = forecast
+	return payload, nil
+}
+
+// pollencomLocation formats the location of a forecast (leaving out the
+// separator if Pollen.com doesn't know the city or state)
+func pollencomLocation(city, state string) string {
+	return strings.Trim(fmt.Sprintf("%s, %s", city, state), ", ")
+}
+
+// pollencomForecastDays builds the forecast days for the indices, using the
+// date of each forecast period (or the forecast date, if the periods don't have one)
+func pollencomForecastDays(response PollencomForecastResponse, indices []float64, scale IndexScale) []ForecastDay {
+	//	The forecast date includes the location's UTC offset
+	start, err := time.Parse(time.RFC3339, response.ForecastDate)
+	if err != nil {
+		start = time.Now().In(stateLocation(response.Location.State))
 	}
 
-	predomPollen := strings.Join(predomPollens, ", ")
+	days := []ForecastDay{}
+	for i, index := range indices {
+		date := start.AddDate(0, 0, i).Format(DateFormat)
 
-	//	Set the properties in the return object:
-	retval = PollenReport{
-		ReportingService:  "Pollen.com",
-		PredominantPollen: predomPollen,
-		Zipcode:           zipcode,
-		Location:          fmt.Sprintf("%s, %s", serviceResponse.Location.City, serviceResponse.Location.State),
-		StartDate:         time.Now(),
-		Data:              dataitems,
+		if i < len(response.Location.Periods) {
+			if period, err := time.Parse(pollencomPeriodFormat, response.Location.Periods[i].Period); err == nil {
+				date = period.Format(DateFormat)
+			}
+		}
+
+		days = append(days, newForecastDay(date, index, scale))
 	}
 
-	xray.AddMetadata(ctx, "PollencomResult", retval)
+	return days
+}
 
-	// Close the segment
-	seg.Close(nil)
+// pollencomUpcoming returns the forecast with just the periods from today on
+// (by their labels or dates).  If there's no period for today, there are no
+// periods at all
+func pollencomUpcoming(response PollencomForecastResponse) PollencomForecastResponse {
+	upcoming := response
+	upcoming.Location.Periods = nil
 
-	return retval, nil
+	if today := pollencomToday(pollencomLabels(response), response.ForecastDate); today >= 0 {
+		upcoming.Location.Periods = response.Location.Periods[today:]
+	}
+
+	return upcoming
+}
+
+// pollencomLabel is how a forecast period says which day it is: its label
+// (like 'Yesterday', 'Today' or 'Tomorrow') and its date
+type pollencomLabel struct {
+	label string
+	date  string
+}
+
+// pollencomLabels returns the label for each period in the forecast
+func pollencomLabels(response PollencomForecastResponse) []pollencomLabel {
+	labels := []pollencomLabel{}
+	for _, period := range response.Location.Periods {
+		labels = append(labels, pollencomLabel{period.Type, period.Period})
+	}
+
+	return labels
+}
+
+// pollencomToday returns which period is today: the one labeled Today, or the
+// one dated on the forecast date.  If the periods aren't labeled or dated,
+// they're taken to start with today.  If there's no period for today, it
+// returns -1
+func pollencomToday(periods []pollencomLabel, forecastDate string) int {
+	for i, period := range periods {
+		if strings.EqualFold(strings.TrimSpace(period.label), "Today") {
+			return i
+		}
+	}
+
+	//	The forecast date includes the location's UTC offset, but the periods don't
+	if forecast, err := time.Parse(time.RFC3339, forecastDate); err == nil {
+		for i, period := range periods {
+			if date, err := time.Parse(pollencomPeriodFormat, period.date); err == nil && date.Format(DateFormat) == forecast.Format(DateFormat) {
+				return i
+			}
+		}
+	}
+
+	//	Labels (or real dates) without today mean there's nothing for today
+	for _, period := range periods {
+		if date, err := time.Parse(pollencomPeriodFormat, period.date); strings.TrimSpace(period.label) != "" || (err == nil && date.Year() > 1) {
+			return -1
+		}
+	}
+
+	if len(periods) == 0 {
+		return -1
+	}
+
+	return 0
 }
@@ -1,16 +1,30 @@
 package data_test
 
 import (
+	"bytes"
 	"context"
+	"net/http"
+	"reflect"
 	"testing"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/danesparza/pollen/data"
 )
 
+const (
+	pollencomForecastPath = "/api/forecast/extended/pollen/30019"
+	pollencomCurrentPath  = "/api/forecast/current/pollen/30019"
+)
+
 func TestPollencom_GetPollenReport_ReturnsValidData(t *testing.T) {
 	//	Arrange
-	service := data.PollencomService{}
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomForecastPath: {file: "pollencom/forecast_30019.json"},
+		pollencomCurrentPath:  {file: "pollencom/current_30019.json"},
+	})
+	defer server.Close()
+
+	service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
 	zipcode := "30019"
 	ctx := context.Background()
 	ctx, seg := xray.BeginSegment(ctx, "unit-test")
@@ -21,9 +35,237 @@ func TestPollencom_GetPollenReport_ReturnsValidData(t *testing.T) {
 
 	//	Assert
 	if err != nil {
-		t.Errorf("Error calling GetPollenReport: %v", err)
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	if expected := []float64{9.1, 4.2, 8.5, 9.7}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected data %v, but got %v", expected, response.Data)
+	}
+
+	expectedDays := []struct {
+		date     string
+		category data.Category
+	}{
+		{"2019-04-18", data.CategoryMediumHigh},
+		{"2019-04-19", data.CategoryLowMedium},
+		{"2019-04-20", data.CategoryMediumHigh},
+		{"2019-04-21", data.CategoryHigh},
+	}
+
+	if len(response.Days) != len(expectedDays) {
+		t.Fatalf("Expected %d forecast days, but got %d", len(expectedDays), len(response.Days))
+	}
+
+	for i, expected := range expectedDays {
+		day := response.Days[i]
+		if day.Date != expected.date || day.Category != expected.category || day.Index != response.Data[i] || !reflect.DeepEqual(day.Scale, data.PollencomScale) || day.Normalized != day.Index {
+			t.Errorf("Expected day %d to be %s/%s, but got %+v", i, expected.date, expected.category, day)
+		}
+	}
+
+	if response.Location != "DACULA, GA" {
+		t.Errorf("Expected location 'DACULA, GA', but got '%s'", response.Location)
+	}
+
+	if response.PredominantPollen == "" {
+		t.Errorf("Expected a predominant pollen, but didn't get one")
+	}
+
+	//	Today's triggers (not yesterday's, which come first in the current conditions)
+	expectedAllergens := []data.Allergen{
+		{Name: "Oak", Genus: "Quercus", PlantType: data.PlantTree},
+		{Name: "Birch", Genus: "Betula", PlantType: data.PlantTree},
+		{Name: "Sycamore", Genus: "Platanus", PlantType: data.PlantTree},
+	}
+	if !reflect.DeepEqual(response.Allergens, expectedAllergens) {
+		t.Errorf("Expected allergens %+v, but got %+v", expectedAllergens, response.Allergens)
+	}
+
+	if response.ReportingService != "Pollen.com" || response.Zipcode != zipcode {
+		t.Errorf("Unexpected service or zipcode: %s / %s", response.ReportingService, response.Zipcode)
 	}
 
 	t.Logf("Returned object: %+v", response)
 
 }
+
+func TestPollencom_GetPollenReport_Failures(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseURL    string
+		routes     map[string]fixture
+		reason     data.FailureReason
+		statusCode int
+	}{
+		{
+			name: "extended forecast blocked",
+			routes: map[string]fixture{
+				pollencomForecastPath: {status: http.StatusForbidden, file: "pollencom/blocked.html"},
+				pollencomCurrentPath:  {file: "pollencom/current_30019.json"},
+			},
+			reason:     data.FailureHTTPStatus,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name: "current conditions unavailable",
+			routes: map[string]fixture{
+				pollencomForecastPath: {file: "pollencom/forecast_30019.json"},
+				pollencomCurrentPath:  {status: http.StatusServiceUnavailable},
+			},
+			reason:     data.FailureHTTPStatus,
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "html instead of json",
+			routes: map[string]fixture{
+				pollencomForecastPath: {file: "pollencom/blocked.html"},
+				pollencomCurrentPath:  {file: "pollencom/current_30019.json"},
+			},
+			reason: data.FailureDecode,
+		},
+		{
+			name:    "connection refused",
+			baseURL: newClosedServerURL(),
+			reason:  data.FailureTransport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//	Arrange
+			server := newFixtureServer(t, tt.routes)
+			defer server.Close()
+
+			url := server.URL
+			if tt.baseURL != "" {
+				url = tt.baseURL
+			}
+
+			service := data.PollencomService{Client: server.Client(), BaseURL: url}
+			ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+			defer seg.Close(nil)
+
+			//	Act
+			_, err := service.GetPollenReport(ctx, "30019")
+
+			//	Assert
+			perr, ok := err.(*data.ProviderError)
+			if !ok {
+				t.Fatalf("Expected a *data.ProviderError, but got %T (%v)", err, err)
+			}
+
+			if perr.Reason != tt.reason || perr.StatusCode != tt.statusCode {
+				t.Errorf("Expected %s/%d, but got %s/%d", tt.reason, tt.statusCode, perr.Reason, perr.StatusCode)
+			}
+		})
+	}
+}
+
+func TestPollencom_GetPollenReport_ShortResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		forecast  string
+		current   string
+		data      []float64
+		dates     []string
+		allergens string
+		location  string
+	}{
+		{
+			name:      "trimmed to yesterday and today",
+			forecast:  "pollencom/forecast_trimmed.json",
+			current:   "pollencom/current_trimmed.json",
+			data:      []float64{9.1},
+			dates:     []string{"2019-04-18"},
+			allergens: "Oak",
+			location:  "DACULA, GA",
+		},
+		{
+			name:      "no current conditions for today",
+			forecast:  "pollencom/forecast_30019.json",
+			current:   "pollencom/current_yesterday.json",
+			data:      []float64{9.1, 4.2, 8.5, 9.7},
+			dates:     []string{"2019-04-18", "2019-04-19", "2019-04-20", "2019-04-21"},
+			allergens: "",
+			location:  "DACULA, GA",
+		},
+		{
+			name:      "unknown zipcode",
+			forecast:  "pollencom/unknown_zip.json",
+			current:   "pollencom/unknown_zip.json",
+			data:      []float64{},
+			dates:     []string{},
+			allergens: "",
+			location:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//	Arrange
+			server := newFixtureServer(t, map[string]fixture{
+				pollencomForecastPath: {file: tt.forecast},
+				pollencomCurrentPath:  {file: tt.current},
+			})
+			defer server.Close()
+
+			service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
+			ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+			defer seg.Close(nil)
+
+			//	Act
+			response, err := service.GetPollenReport(ctx, "30019")
+
+			//	Assert
+			if err != nil {
+				t.Fatalf("Error calling GetPollenReport: %v", err)
+			}
+
+			dates := []string{}
+			for _, day := range response.Days {
+				dates = append(dates, day.Date)
+			}
+
+			if !reflect.DeepEqual(response.Data, tt.data) || !reflect.DeepEqual(dates, tt.dates) {
+				t.Errorf("Expected data %v on %v, but got %v on %v", tt.data, tt.dates, response.Data, dates)
+			}
+
+			if response.PredominantPollen != tt.allergens || response.Location != tt.location {
+				t.Errorf("Expected '%s' in '%s', but got '%s' in '%s'", tt.allergens, tt.location, response.PredominantPollen, response.Location)
+			}
+		})
+	}
+}
+
+func TestPollencom_GetRawReport_ReturnsPayloadsAsIs(t *testing.T) {
+	//	Arrange
+	server := newFixtureServer(t, map[string]fixture{
+		pollencomForecastPath: {file: "pollencom/forecast_30019.json"},
+		pollencomCurrentPath:  {file: "pollencom/current_30019.json"},
+	})
+	defer server.Close()
+
+	service := data.PollencomService{Client: server.Client(), BaseURL: server.URL}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	payloads, err := service.GetRawReport(ctx, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetRawReport: %v", err)
+	}
+
+	if len(payloads) != 2 || payloads[0].Name != "extended" || payloads[1].Name != "current" {
+		t.Fatalf("Unexpected payloads: %+v", payloads)
+	}
+
+	if expected := loadFixture(t, "pollencom/forecast_30019.json"); !bytes.Equal(payloads[0].Body, expected) {
+		t.Errorf("Expected the extended forecast as-is, but got %s", payloads[0].Body)
+	}
+
+	if payloads[1].URL != server.URL+pollencomCurrentPath {
+		t.Errorf("Unexpected url %s", payloads[1].URL)
+	}
+}
@@ -0,0 +1,17 @@
+package data
+
+import "context"
+
+// RawPayload is a response from a service's upstream API, as-is
+type RawPayload struct {
+	Name string // Which API the payload is from (like 'extended' or 'current')
+	URL  string // The url that was called
+	Body []byte // The response body
+}
+
+// RawProvider is implemented by services that can return their upstream API
+// responses as-is (handy for seeing why a report looks the way it does)
+type RawProvider interface {
+	// GetRawReport gets the API responses the pollen report is built from
+	GetRawReport(ctx context.Context, zipcode string) ([]RawPayload, error)
+}
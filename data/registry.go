@@ -0,0 +1,277 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ProviderInfo describes a registered provider and what it can do
+type ProviderInfo struct {
+	Name           string   `json:"name"`                // The stable name the provider is registered (and selected) under, like 'pollencom'
+	ForecastDays   int      `json:"forecast_days"`       // How many days of forecast it has, including today
+	Countries      []string `json:"countries,omitempty"` // The ISO country codes it covers (empty if it isn't limited to a list of countries)
+	Species        bool     `json:"species"`             // True if it reports individual plant species
+	RequiresAPIKey bool     `json:"requires_api_key"`    // True if it can't be used without an API key
+	Priority       int      `json:"priority,omitempty"`  // The hedged strategy's priority tier for it (optional -- lower tiers are called first)
+}
+
+// Covers returns true if the provider covers the country (every provider that
+// isn't limited to a list of countries does)
+func (i ProviderInfo) Covers(country string) bool {
+	if len(i.Countries) == 0 {
+		return true
+	}
+
+	for _, covered := range i.Countries {
+		if strings.EqualFold(covered, country) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProviderSettings are what a provider factory is given to build its service
+type ProviderSettings struct {
+	APIKey      string // The API key for the provider (if it needs one)
+	Country     string // The ISO country code for the postal codes the provider is given (optional -- defaults to ZipcodeCountry)
+	Coordinates string // A JSON file with the coordinates for each zipcode, for providers that need them (optional -- defaults to the Open-Meteo geocoding API)
+}
+
+// SelectedProvider is a service the registry built, with the provider it was
+// built for
+type SelectedProvider struct {
+	Info    ProviderInfo  // The registered provider
+	Service PollenService // The service
+}
+
+// country returns the ISO country code for the settings (ZipcodeCountry if it isn't set)
+func (s ProviderSettings) country() string {
+	if country := strings.ToUpper(strings.TrimSpace(s.Country)); country != "" {
+		return country
+	}
+
+	return ZipcodeCountry
+}
+
+// geocoder returns the static geocoder for the settings' coordinates file, or
+// nil if there isn't one (so the service uses its default geocoder)
+func (s ProviderSettings) geocoder() (Geocoder, error) {
+	if strings.TrimSpace(s.Coordinates) == "" {
+		return nil, nil
+	}
+
+	geocoder, err := ReadStaticGeocoder(s.Coordinates)
+	if err != nil {
+		return nil, err
+	}
+
+	return geocoder, nil
+}
+
+// ProviderFactory builds the service for a provider
+type ProviderFactory func(settings ProviderSettings) (PollenService, error)
+
+// Registry is a set of providers, each registered under a stable name with a
+// factory and its capabilities.  Names are matched without regard to case.
+// The zero value is ready to use
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]registeredProvider
+	order     []string // The normalized names, in the order they were registered
+}
+
+// registeredProvider is a provider in a registry
+type registeredProvider struct {
+	info    ProviderInfo
+	factory ProviderFactory
+}
+
+// DefaultRegistry has the built-in providers: nasacort, pollencom, openmeteo
+// and google
+var DefaultRegistry = &Registry{}
+
+func init() {
+	DefaultRegistry.MustRegister(ProviderInfo{Name: "nasacort", ForecastDays: 4, Countries: []string{"US"}}, func(ProviderSettings) (PollenService, error) {
+		return NasacortService{}, nil
+	})
+	DefaultRegistry.MustRegister(ProviderInfo{Name: "pollencom", ForecastDays: 4, Countries: []string{"US"}}, func(ProviderSettings) (PollenService, error) {
+		return PollencomService{}, nil
+	})
+	DefaultRegistry.MustRegister(ProviderInfo{Name: "openmeteo", ForecastDays: OpenMeteoForecastDays, Countries: OpenMeteoCountries, Species: true}, func(settings ProviderSettings) (PollenService, error) {
+		geocoder, err := settings.geocoder()
+		if err != nil {
+			return nil, err
+		}
+
+		return OpenMeteoService{Country: settings.Country, Geocoder: geocoder}, nil
+	})
+	DefaultRegistry.MustRegister(ProviderInfo{Name: "google", ForecastDays: GoogleForecastDays, Species: true, RequiresAPIKey: true}, func(settings ProviderSettings) (PollenService, error) {
+		geocoder, err := settings.geocoder()
+		if err != nil {
+			return nil, err
+		}
+
+		return GoogleService{APIKey: settings.APIKey, Geocoder: geocoder}, nil
+	})
+}
+
+// Register adds the provider to the registry.  It's an error to register a
+// provider without a name or factory, or to use a name that's already taken
+func (r *Registry) Register(info ProviderInfo, factory ProviderFactory) error {
+	key := registryKey(info.Name)
+	if key == "" || factory == nil {
+		return fmt.Errorf("A provider needs a name and a factory to be registered")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.providers == nil {
+		r.providers = map[string]registeredProvider{}
+	}
+
+	if _, ok := r.providers[key]; ok {
+		return fmt.Errorf("There's already a provider registered as '%s'", info.Name)
+	}
+
+	r.providers[key] = registeredProvider{info: info, factory: factory}
+	r.order = append(r.order, key)
+
+	return nil
+}
+
+// MustRegister is like Register, but panics if the provider can't be
+// registered.  It's meant for registering providers at init time
+func (r *Registry) MustRegister(info ProviderInfo, factory ProviderFactory) {
+	if err := r.Register(info, factory); err != nil {
+		panic(err)
+	}
+}
+
+// Providers returns the registered providers, in the order they were registered
+func (r *Registry) Providers() []ProviderInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	retval := []ProviderInfo{}
+	for _, key := range r.order {
+		retval = append(retval, r.providers[key].info)
+	}
+
+	return retval
+}
+
+// Info returns the registered provider with the name.  If there isn't one, ok is false
+func (r *Registry) Info(name string) (ProviderInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	provider, ok := r.providers[registryKey(name)]
+	return provider.info, ok
+}
+
+// New builds the service for the named provider
+func (r *Registry) New(name string, settings ProviderSettings) (PollenService, error) {
+	r.mu.RLock()
+	provider, ok := r.providers[registryKey(name)]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown provider '%s' -- the registered providers are %s", name, strings.Join(r.names(), ", "))
+	}
+
+	if provider.info.RequiresAPIKey && strings.TrimSpace(settings.APIKey) == "" {
+		return nil, fmt.Errorf("The %s provider needs an API key", provider.info.Name)
+	}
+
+	if country := settings.country(); !provider.info.Covers(country) {
+		return nil, fmt.Errorf("The %s provider doesn't cover %s -- it covers %s", provider.info.Name, country, strings.Join(provider.info.Countries, ", "))
+	}
+
+	return provider.factory(settings)
+}
+
+// Select builds the services for the named providers, in order (skipping any
+// repeats).  If no names are passed, every registered provider that can be
+// built is selected -- so providers that need an API key are left out if they
+// don't have one, and so are providers that don't cover their country.
+// settings returns the settings for each provider (by its registered name),
+// and can be nil
+func (r *Registry) Select(names []string, settings func(name string) ProviderSettings) ([]PollenService, error) {
+	selected, err := r.SelectProviders(names, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []PollenService{}
+	for _, provider := range selected {
+		services = append(services, provider.Service)
+	}
+
+	return services, nil
+}
+
+// SelectProviders is like Select, but returns the provider each service was
+// built for too
+func (r *Registry) SelectProviders(names []string, settings func(name string) ProviderSettings) ([]SelectedProvider, error) {
+	if settings == nil {
+		settings = func(string) ProviderSettings { return ProviderSettings{} }
+	}
+
+	explicit := len(names) > 0
+	if !explicit {
+		for _, info := range r.Providers() {
+			names = append(names, info.Name)
+		}
+	}
+
+	providers := []SelectedProvider{}
+	selected := map[string]bool{}
+	for _, name := range names {
+		info, ok := r.Info(name)
+		if ok && selected[registryKey(name)] {
+			continue
+		}
+
+		//	Use the registered name for the settings, whatever case was asked for
+		if ok {
+			name = info.Name
+		}
+
+		provider := settings(name)
+		if !explicit && info.RequiresAPIKey && strings.TrimSpace(provider.APIKey) == "" {
+			continue
+		}
+
+		if !explicit && !info.Covers(provider.country()) {
+			continue
+		}
+
+		service, err := r.New(name, provider)
+		if err != nil {
+			return nil, err
+		}
+
+		selected[registryKey(name)] = true
+		providers = append(providers, SelectedProvider{Info: info, Service: service})
+	}
+
+	return providers, nil
+}
+
+// names returns the names of the registered providers
+func (r *Registry) names() []string {
+	names := []string{}
+	for _, info := range r.Providers() {
+		names = append(names, info.Name)
+	}
+
+	return names
+}
+
+// registryKey normalizes a provider name for lookups
+func registryKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,138 @@
+package data_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danesparza/pollen/data"
+)
+
+// newTestRegistry returns a registry with fake providers: 'alpha' and 'beta',
+// and 'keyed' (which needs an API key)
+func newTestRegistry(t *testing.T) *data.Registry {
+	t.Helper()
+
+	registry := &data.Registry{}
+	for _, info := range []data.ProviderInfo{
+		{Name: "alpha", ForecastDays: 4, Countries: []string{"US"}},
+		{Name: "keyed", ForecastDays: 5, Species: true, RequiresAPIKey: true},
+		{Name: "beta", ForecastDays: 3},
+	} {
+		name := info.Name
+		registry.MustRegister(info, func(settings data.ProviderSettings) (data.PollenService, error) {
+			return fakeService{name: name + settings.APIKey}, nil
+		})
+	}
+
+	return registry
+}
+
+// serviceNames returns the name of each service
+func serviceNames(services []data.PollenService) string {
+	names := []string{}
+	for _, service := range services {
+		names = append(names, data.ServiceName(service))
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestRegistry_Register_RejectsDuplicateNames(t *testing.T) {
+	//	Arrange
+	registry := newTestRegistry(t)
+	factory := func(data.ProviderSettings) (data.PollenService, error) { return fakeService{}, nil }
+
+	//	Act
+	duplicate := registry.Register(data.ProviderInfo{Name: " ALPHA "}, factory)
+	unnamed := registry.Register(data.ProviderInfo{}, factory)
+
+	//	Assert
+	if duplicate == nil || unnamed == nil {
+		t.Errorf("Expected the duplicate and unnamed providers to be rejected, but got %v and %v", duplicate, unnamed)
+	}
+
+	if providers := registry.Providers(); len(providers) != 3 || providers[1].Name != "keyed" || !providers[1].RequiresAPIKey || providers[1].ForecastDays != 5 {
+		t.Errorf("Expected the 3 providers in the order they were registered, but got %+v", providers)
+	}
+}
+
+func TestRegistry_Select_ChoosesProviders(t *testing.T) {
+	//	Arrange
+	registry := newTestRegistry(t)
+	withKey := func(name string) data.ProviderSettings {
+		if name == "keyed" {
+			return data.ProviderSettings{APIKey: "-key"}
+		}
+		return data.ProviderSettings{}
+	}
+
+	tests := []struct {
+		name     string
+		names    []string
+		settings func(string) data.ProviderSettings
+		expected string
+		err      string
+	}{
+		{"every available provider", nil, nil, "alpha,beta", ""},
+		{"every provider with keys", nil, withKey, "alpha,keyed-key,beta", ""},
+		{"in the requested order", []string{"Beta", "alpha", "BETA"}, nil, "beta,alpha", ""},
+		{"with a key", []string{"keyed"}, withKey, "keyed-key", ""},
+		{"missing key", []string{"alpha", "keyed"}, nil, "", "The keyed provider needs an API key"},
+		{"unknown provider", []string{"alpha", "weather.com"}, nil, "", "Unknown provider 'weather.com' -- the registered providers are alpha, keyed, beta"},
+	}
+
+	for _, test := range tests {
+		//	Act
+		services, err := registry.Select(test.names, test.settings)
+
+		//	Assert
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error '%s', but got %v", test.name, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil || serviceNames(services) != test.expected {
+			t.Errorf("%s: expected %s, but got %s (%v)", test.name, test.expected, serviceNames(services), err)
+		}
+	}
+}
+
+func TestDefaultRegistry_HasBuiltInProviders(t *testing.T) {
+	//	Act
+	services, err := data.DefaultRegistry.Select(nil, nil)
+	google, ok := data.DefaultRegistry.Info("Google")
+
+	//	Assert
+	if err != nil || serviceNames(services) != "Nasacort,Pollen.com" {
+		t.Errorf("Expected the built-in providers for US zipcodes that don't need a key, but got %s (%v)", serviceNames(services), err)
+	}
+
+	if !ok || !google.RequiresAPIKey || !google.Species || google.ForecastDays != data.GoogleForecastDays {
+		t.Errorf("Expected Google's capabilities, but got %+v", google)
+	}
+}
+
+func TestDefaultRegistry_OpenMeteo_OnlyCoversGermany(t *testing.T) {
+	//	Arrange
+	country := func(code string) func(string) data.ProviderSettings {
+		return func(string) data.ProviderSettings {
+			return data.ProviderSettings{Country: code}
+		}
+	}
+
+	//	Act
+	_, usErr := data.DefaultRegistry.Select([]string{"openmeteo"}, nil)
+	_, gbErr := data.DefaultRegistry.Select([]string{"openmeteo"}, country("GB"))
+	services, deErr := data.DefaultRegistry.Select([]string{"openmeteo"}, country("de"))
+
+	//	Assert
+	if usErr == nil || gbErr == nil {
+		t.Errorf("Expected errors picking Open-Meteo for US zipcodes and British postcodes, but got %v and %v", usErr, gbErr)
+	}
+
+	if deErr != nil || serviceNames(services) != "Open-Meteo" {
+		t.Errorf("Expected Open-Meteo for German postal codes, but got %s (%v)", serviceNames(services), deErr)
+	}
+}
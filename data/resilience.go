@@ -0,0 +1,355 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// DefaultAttemptTimeout is how long each call to a service can take when
+// Resilience doesn't set a timeout
+const DefaultAttemptTimeout = 4 * time.Second
+
+// DefaultRetries is how many times a retryable failure is retried when
+// Resilience doesn't set a number of retries
+const DefaultRetries = 2
+
+// DefaultRetryBackoff is the most a service waits before its first retry when
+// Resilience doesn't set a backoff
+const DefaultRetryBackoff = 100 * time.Millisecond
+
+// DefaultBreakerThreshold is how many failures in a row open a service's
+// circuit breaker when Resilience doesn't set a threshold
+const DefaultBreakerThreshold = 5
+
+// DefaultBreakerCooldown is how long a circuit breaker stays open (before it
+// lets a probe call through) when Resilience doesn't set a cooldown
+const DefaultBreakerCooldown = 30 * time.Second
+
+// BreakerState is the state of a service's circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed means the service is called as usual
+	BreakerClosed BreakerState = "closed"
+
+	// BreakerOpen means the service kept failing, so it's skipped
+	BreakerOpen BreakerState = "open"
+
+	// BreakerHalfOpen means the service is being probed to see if it's back
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// Resilience makes services more dependable: each call gets its own timeout,
+// retryable failures (5xx responses, timeouts and connection problems) are
+// retried with jittered backoff, and each service has a circuit breaker that
+// skips it after repeated failures.  Once the cooldown passes, a few half-open
+// probe calls are let through -- if they succeed the breaker closes again.
+// Breaker state is kept for each service name, so use the same Resilience for
+// every request
+type Resilience struct {
+	AttemptTimeout   time.Duration    // How long each call can take (optional -- defaults to DefaultAttemptTimeout)
+	Retries          int              // How many times a retryable failure is retried (optional -- defaults to DefaultRetries, and negative turns retries off)
+	Backoff          time.Duration    // The most to wait before the first retry -- it doubles for each retry after that (optional -- defaults to DefaultRetryBackoff)
+	BreakerThreshold int              // How many failures in a row open the breaker (optional -- defaults to DefaultBreakerThreshold)
+	BreakerCooldown  time.Duration    // How long the breaker stays open (optional -- defaults to DefaultBreakerCooldown)
+	HalfOpenProbes   int              // How many calls are let through while the breaker is half open (optional -- defaults to 1)
+	Now              func() time.Time // The current time (optional -- defaults to time.Now)
+
+	mu       sync.Mutex
+	breakers map[string]*breaker // The circuit breaker for each service, by name
+}
+
+// breaker is the circuit breaker state for a service
+type breaker struct {
+	state    BreakerState
+	failures int       // Failures in a row
+	openedAt time.Time // When the breaker last opened
+	probes   int       // Probe calls let through since the breaker went half open
+}
+
+// Service returns the service with timeouts, retries and a circuit breaker.
+// The resilient service only has the service's name and scale, but its kinds
+// of forecasts and history still go through the breaker (and get retried)
+// when they're used with GetForecasts and GetPollenHistory
+func (r *Resilience) Service(service PollenService) PollenService {
+	return resilientService{resilience: r, service: service}
+}
+
+// Services returns each of the services with timeouts, retries and a circuit breaker
+func (r *Resilience) Services(services []PollenService) []PollenService {
+	retval := []PollenService{}
+	for _, service := range services {
+		retval = append(retval, r.Service(service))
+	}
+
+	return retval
+}
+
+// BreakerState returns the state of the circuit breaker for the named service
+func (r *Resilience) BreakerState(name string) BreakerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[name]
+	if !ok {
+		return BreakerClosed
+	}
+
+	//	An open breaker is half open once its cooldown has passed
+	if b.state == BreakerOpen && !r.now().Before(b.openedAt.Add(r.cooldown())) {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// resilientService is a service with timeouts, retries and a circuit breaker
+type resilientService struct {
+	resilience *Resilience
+	service    PollenService
+}
+
+// Name returns the name of the resilient service
+func (s resilientService) Name() string {
+	return ServiceName(s.service)
+}
+
+// Scale returns the native scale of the resilient service
+func (s resilientService) Scale() IndexScale {
+	return ServiceScale(s.service)
+}
+
+// Unwrap returns the service that's made resilient
+func (s resilientService) Unwrap() PollenService {
+	return s.service
+}
+
+// rewrap returns the service with the same timeouts, retries and circuit
+// breaker (the breaker is kept by name, so it's shared with this service)
+func (s resilientService) rewrap(service PollenService) PollenService {
+	return s.resilience.Service(service)
+}
+
+// GetPollenReport gets the report from the service, retrying retryable
+// failures -- unless its circuit breaker is open
+func (s resilientService) GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error) {
+	r := s.resilience
+	name := s.Name()
+
+	state, allowed := r.allow(name)
+	annotateBreaker(ctx, name, state)
+	if !allowed {
+		return PollenReport{}, &ProviderError{Service: name, Reason: FailureCircuitOpen, Err: errors.New("The circuit breaker is open after repeated failures")}
+	}
+
+	retries := r.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+
+	var report PollenReport
+	var err error
+	for attempt := 0; ; attempt++ {
+		report, err = s.attempt(ctx, zipcode)
+		if err == nil || attempt >= retries || !isRetryable(err) || !r.wait(ctx, attempt) {
+			break
+		}
+
+		xray.AddMetadata(ctx, name+"Retries", attempt+1)
+	}
+
+	//	The caller running out of time isn't the service's fault
+	if err != nil && ctx.Err() != nil {
+		r.release(name)
+		return report, err
+	}
+
+	annotateBreaker(ctx, name, r.record(name, err == nil))
+
+	return report, err
+}
+
+// attempt calls the service once, with its own timeout
+func (s resilientService) attempt(ctx context.Context, zipcode string) (PollenReport, error) {
+	timeout := s.resilience.AttemptTimeout
+	if timeout <= 0 {
+		timeout = DefaultAttemptTimeout
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	//	Recover here too, so a panic still counts against the breaker
+	report, err := getReportSafely(attemptCtx, s.service, zipcode)
+
+	//	If the attempt ran out of time (but the caller didn't), call it a timeout
+	if err != nil && attemptCtx.Err() != nil && ctx.Err() == nil {
+		return report, &ProviderError{Service: s.Name(), Reason: FailureTimeout, Err: attemptCtx.Err()}
+	}
+
+	return report, err
+}
+
+// wait sleeps before the retry after the attempt (a random time up to the
+// backoff, doubled for each attempt).  It returns false if the context is
+// done first
+func (r *Resilience) wait(ctx context.Context, attempt int) bool {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	backoff <<= uint(attempt)
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff) + 1)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// allow returns the state of the service's breaker, and whether the service
+// can be called
+func (r *Resilience) allow(name string) (BreakerState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.breaker(name)
+	if b.state == BreakerOpen && !r.now().Before(b.openedAt.Add(r.cooldown())) {
+		b.state = BreakerHalfOpen
+		b.probes = 0
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return b.state, false
+
+	case BreakerHalfOpen:
+		probes := r.HalfOpenProbes
+		if probes <= 0 {
+			probes = 1
+		}
+		if b.probes >= probes {
+			return b.state, false
+		}
+		b.probes++
+	}
+
+	return b.state, true
+}
+
+// record updates the service's breaker with the result of a call, and returns
+// its new state
+func (r *Resilience) record(name string, succeeded bool) BreakerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.breaker(name)
+	if succeeded {
+		b.state = BreakerClosed
+		b.failures = 0
+		return b.state
+	}
+
+	threshold := r.BreakerThreshold
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= threshold {
+		b.state = BreakerOpen
+		b.openedAt = r.now()
+	}
+
+	return b.state
+}
+
+// release gives back a half-open probe that didn't find out anything
+func (r *Resilience) release(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b := r.breaker(name); b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// breaker returns the breaker for the service (the lock must be held)
+func (r *Resilience) breaker(name string) *breaker {
+	if r.breakers == nil {
+		r.breakers = map[string]*breaker{}
+	}
+
+	b, ok := r.breakers[name]
+	if !ok {
+		b = &breaker{state: BreakerClosed}
+		r.breakers[name] = b
+	}
+
+	return b
+}
+
+// cooldown returns how long breakers stay open
+func (r *Resilience) cooldown() time.Duration {
+	if r.BreakerCooldown > 0 {
+		return r.BreakerCooldown
+	}
+
+	return DefaultBreakerCooldown
+}
+
+// now returns the current time
+func (r *Resilience) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+
+	return time.Now()
+}
+
+// annotateBreaker records the state of the service's breaker in X-Ray (as
+// breaker_{service}, with anything but letters and numbers as underscores)
+func annotateBreaker(ctx context.Context, name string, state BreakerState) {
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+
+	xray.AddAnnotation(ctx, "breaker_"+key, string(state))
+}
+
+// isRetryable returns true if the failure might not happen again: a timeout,
+// a problem reaching the service, or a 5xx (or 429) response
+func isRetryable(err error) bool {
+	if perr, ok := err.(*ProviderError); ok {
+		switch perr.Reason {
+		case FailureTransport, FailureTimeout:
+			return true
+		case FailureHTTPStatus:
+			return perr.StatusCode >= http.StatusInternalServerError || perr.StatusCode == http.StatusTooManyRequests
+		case FailureUnknown:
+			return isRetryable(perr.Err)
+		}
+		return false
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	return err != nil && strings.Contains(err.Error(), "connection reset")
+}
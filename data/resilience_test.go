@@ -0,0 +1,249 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// scriptedService is a PollenService that fails with each of its errors in
+// turn, then succeeds
+type scriptedService struct {
+	errs  []error
+	calls *int32
+}
+
+func (s scriptedService) Name() string {
+	return "Scripted"
+}
+
+func (s scriptedService) GetPollenReport(ctx context.Context, zipcode string) (data.PollenReport, error) {
+	call := int(atomic.AddInt32(s.calls, 1))
+	if call <= len(s.errs) {
+		return data.PollenReport{}, s.errs[call-1]
+	}
+
+	return data.PollenReport{Zipcode: zipcode, ReportingService: "Scripted", Data: []float64{5}}, nil
+}
+
+func TestResilience_RetryableFailures_AreRetried(t *testing.T) {
+	//	Arrange
+	var calls int32
+	unavailable := &data.ProviderError{Reason: data.FailureHTTPStatus, StatusCode: 503}
+	reset := &data.ProviderError{Reason: data.FailureTransport, Err: errors.New("read: connection reset by peer")}
+	resilience := &data.Resilience{Backoff: time.Millisecond}
+	service := resilience.Service(scriptedService{errs: []error{unavailable, reset}, calls: &calls})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	report, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err != nil || report.Data[0] != 5 || calls != 3 {
+		t.Errorf("Expected the report after two retries, but got %+v (%v) after %d calls", report, err, calls)
+	}
+}
+
+func TestResilience_NonRetryableFailures_AreNotRetried(t *testing.T) {
+	//	Arrange
+	var calls int32
+	notFound := &data.ProviderError{Reason: data.FailureHTTPStatus, StatusCode: 404}
+	resilience := &data.Resilience{Backoff: time.Millisecond}
+	service := resilience.Service(scriptedService{errs: []error{notFound}, calls: &calls})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if err != notFound || calls != 1 {
+		t.Errorf("Expected the 404 without a retry, but got %v after %d calls", err, calls)
+	}
+}
+
+func TestResilience_SlowAttempt_TimesOut(t *testing.T) {
+	//	Arrange
+	resilience := &data.Resilience{AttemptTimeout: 20 * time.Millisecond, Retries: -1}
+	service := resilience.Service(fakeService{name: "Slow", delay: time.Second})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	start := time.Now()
+	_, err := service.GetPollenReport(ctx, "30019")
+	elapsed := time.Since(start)
+
+	//	Assert
+	perr, ok := err.(*data.ProviderError)
+	if !ok || perr.Reason != data.FailureTimeout {
+		t.Errorf("Expected a timeout, but got %T (%v)", err, err)
+	}
+
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the attempt to stop at its timeout, but it took %s", elapsed)
+	}
+}
+
+func TestResilience_RepeatedFailures_OpenAndCloseBreaker(t *testing.T) {
+	//	Arrange
+	var down, calls int32 = 1, 0
+	index := int32(7)
+	now := &clock{now: time.Date(2019, 4, 18, 16, 0, 0, 0, time.UTC)}
+	resilience := &data.Resilience{Retries: -1, BreakerThreshold: 3, BreakerCooldown: time.Minute, Now: now.Now}
+	service := resilience.Service(switchService{down: &down, calls: &calls, index: &index})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act -- the breaker opens after three failures, so the fourth call is skipped
+	for i := 0; i < 4; i++ {
+		service.GetPollenReport(ctx, "30019")
+	}
+	_, skippedErr := service.GetPollenReport(ctx, "30019")
+	openCalls, openState := atomic.LoadInt32(&calls), resilience.BreakerState("Switch")
+
+	//	After the cooldown, a probe that fails opens it again
+	now.now = now.now.Add(time.Minute)
+	halfOpenState := resilience.BreakerState("Switch")
+	service.GetPollenReport(ctx, "30019")
+	reopenedState := resilience.BreakerState("Switch")
+
+	//	... and a probe that succeeds closes it
+	now.now = now.now.Add(time.Minute)
+	atomic.StoreInt32(&down, 0)
+	report, err := service.GetPollenReport(ctx, "30019")
+	closedState := resilience.BreakerState("Switch")
+
+	//	Assert
+	if openCalls != 3 || openState != data.BreakerOpen {
+		t.Errorf("Expected the breaker to open after 3 calls, but it's %s after %d calls", openState, openCalls)
+	}
+
+	if perr, ok := skippedErr.(*data.ProviderError); !ok || perr.Reason != data.FailureCircuitOpen {
+		t.Errorf("Expected the skipped call to fail with an open circuit, but got %T (%v)", skippedErr, skippedErr)
+	}
+
+	if halfOpenState != data.BreakerHalfOpen || reopenedState != data.BreakerOpen {
+		t.Errorf("Expected the breaker to go half open, then open again, but got %s and %s", halfOpenState, reopenedState)
+	}
+
+	if err != nil || report.Data[0] != 7 || closedState != data.BreakerClosed {
+		t.Errorf("Expected the probe to close the breaker, but got %+v (%v) and %s", report, err, closedState)
+	}
+}
+
+func TestResilience_HalfOpen_LimitsProbes(t *testing.T) {
+	//	Arrange
+	var calls int32
+	now := &clock{now: time.Date(2019, 4, 18, 16, 0, 0, 0, time.UTC)}
+	resilience := &data.Resilience{Retries: -1, BreakerThreshold: 1, BreakerCooldown: time.Minute, Now: now.Now}
+	slow := resilience.Service(fakeService{name: "Slow", delay: 200 * time.Millisecond, err: errors.New("down")})
+	counting := resilience.Service(countingService{name: "Slow", calls: &calls, report: data.PollenReport{Data: []float64{1}}})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	slow.GetPollenReport(ctx, "30019")
+	now.now = now.now.Add(time.Minute)
+
+	//	Act -- while the slow probe is out, other calls are skipped
+	done := make(chan struct{})
+	go func() {
+		slow.GetPollenReport(ctx, "30019")
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err := counting.GetPollenReport(ctx, "30019")
+	<-done
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailureCircuitOpen || atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("Expected the call to be skipped while the probe is out, but got %v", err)
+	}
+}
+
+func TestResilience_Panics_CountAgainstBreaker(t *testing.T) {
+	//	Arrange
+	resilience := &data.Resilience{BreakerThreshold: 2}
+	service := resilience.Service(panickingService{})
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := service.GetPollenReport(ctx, "30019")
+	service.GetPollenReport(ctx, "30019")
+
+	//	Assert
+	if perr, ok := err.(*data.ProviderError); !ok || perr.Reason != data.FailurePanic {
+		t.Errorf("Expected a panic failure (without retries), but got %T (%v)", err, err)
+	}
+
+	if state := resilience.BreakerState("Panicking"); state != data.BreakerOpen {
+		t.Errorf("Expected the breaker to open after 2 panics, but it's %s", state)
+	}
+}
+
+func TestResilience_ForecastsAndHistory_AreRetried(t *testing.T) {
+	//	Arrange
+	asthma := loadFixture(t, "pollencom/asthma_30019.json")
+	historic := loadFixture(t, "pollencom/historic_30019.json")
+
+	//	The first call to each API fails
+	var calls int32
+	failed := map[string]bool{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		mu.Lock()
+		first := !failed[req.URL.Path]
+		failed[req.URL.Path] = true
+		mu.Unlock()
+
+		if first {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch req.URL.Path {
+		case pollencomAsthmaPath:
+			rw.Write(asthma)
+		case pollencomHistoricPath:
+			rw.Write(historic)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer server.Close()
+
+	resilience := &data.Resilience{Backoff: time.Millisecond}
+	services := resilience.Services([]data.PollenService{data.PollencomService{Client: server.Client(), BaseURL: server.URL}})
+	day := time.Date(2019, 3, 30, 0, 0, 0, 0, time.UTC)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	forecast, forecastErr := data.GetForecast(ctx, services, data.KindAsthma, "30019")
+	history, historyErr := data.GetPollenHistory(ctx, services, "30019", day, day)
+
+	//	Assert
+	if forecastErr != nil || forecast.Service != "Pollen.com" || len(forecast.Days) != 5 {
+		t.Errorf("Expected the asthma forecast after a retry, but got %+v (%v)", forecast, forecastErr)
+	}
+
+	if historyErr != nil || len(history.Days) != 1 {
+		t.Errorf("Expected a single day of history after a retry, but got %+v (%v)", history, historyErr)
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected each API to be called twice, but got %d calls", calls)
+	}
+}
@@ -2,6 +2,8 @@ package data
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
@@ -16,6 +18,29 @@ type PollenReport struct {
 	Data              []float64 `json:"data"`               //	Pollen data indices -- one for today and each future day
 	ReportingService  string    `json:"service"`            // The reporting service
 	Version           string    `json:"version"`            // Service version information
+
+	Normalized        []float64                 `json:"normalized,omitempty"`         // The pollen data indices, converted to the canonical 0-12 scale
+	Days              []ForecastDay             `json:"days,omitempty"`               // The forecast for each calendar day, starting with today
+	Allergens         []Allergen                `json:"allergens,omitempty"`          // The predominant pollen allergens in the report period
+	PlantDescriptions []PlantDescription        `json:"plant_descriptions,omitempty"` // What the plants in the report look like (for services that describe them)
+	Forecasts         map[ForecastKind]Forecast `json:"forecasts,omitempty"`          // Each requested kind of forecast (if specific kinds were requested)
+	History           *History                  `json:"history,omitempty"`            // The observed pollen for past days (if history was requested)
+	Series            *IndexSeries              `json:"series,omitempty"`             // The archived daily index (if the series was requested)
+	Sources           []SourceReport            `json:"sources,omitempty"`            // The report from each service (for merged reports)
+	Disagreement      []float64                 `json:"disagreement,omitempty"`       // How much the services disagree (standard deviation) -- one for each day in Data
+	CacheHit          bool                      `json:"cache_hit"`                    // True if the report came from the cache instead of the services
+	Stale             bool                      `json:"stale,omitempty"`              // True if the services failed, so this is the last good report for the zipcode
+	FetchedAt         *time.Time                `json:"fetched_at,omitempty"`         // When a stale report was fetched from the services
+}
+
+// SourceReport is what a single service reported, for reports merged from multiple services
+type SourceReport struct {
+	Service           string     `json:"service"`                      // The reporting service
+	PredominantPollen string     `json:"predominant_pollen,omitempty"` // The predominant pollen the service reported
+	Allergens         []Allergen `json:"allergens,omitempty"`          // The predominant pollen allergens the service reported
+	Data              []float64  `json:"data,omitempty"`               // The pollen data indices the service reported
+	Normalized        []float64  `json:"normalized,omitempty"`         // The pollen data indices, on the canonical scale
+	Error             string     `json:"error,omitempty"`              // Why the service didn't report (if it failed)
 }
 
 // PollenService is the interface for all services that can fetch pollen data
@@ -24,39 +49,189 @@ type PollenService interface {
 	GetPollenReport(ctx context.Context, zipcode string) (PollenReport, error)
 }
 
-// GetPollenReport calls all services in parallel and returns the first result
-func GetPollenReport(ctx context.Context, services []PollenService, zipcode string) PollenReport {
+// ServiceName returns the name of the passed service.  Services can provide
+// their own name with a Name() method -- otherwise the type name is used
+func ServiceName(service PollenService) string {
+	if named, ok := service.(interface{ Name() string }); ok {
+		return named.Name()
+	}
 
-	ch := make(chan PollenReport, 1)
+	return fmt.Sprintf("%T", service)
+}
+
+// rewrapper is implemented by service wrappers that can wrap another service
+// the same way -- so a capability adapted from the service they wrap (like a
+// kind of forecast or history) is still called through the wrapper
+type rewrapper interface {
+	Unwrap() PollenService
+	rewrap(service PollenService) PollenService
+}
+
+// adaptService adapts a capability of the service (or of the service it wraps)
+// to a PollenService with adapt, keeping the wrappers that can wrap the adapted
+// service.  ok is false if the service doesn't have the capability
+func adaptService(service PollenService, adapt func(PollenService) (PollenService, bool)) (PollenService, bool) {
+	if adapted, ok := adapt(service); ok {
+		return adapted, true
+	}
+
+	wrapper, ok := service.(rewrapper)
+	if !ok {
+		return nil, false
+	}
+
+	adapted, ok := adaptService(wrapper.Unwrap(), adapt)
+	if !ok {
+		return nil, false
+	}
+
+	return wrapper.rewrap(adapted), true
+}
+
+// minDatapoints returns how many datapoints make a valid result for the
+// service (2, unless the service -- or the service it wraps -- needs fewer)
+func minDatapoints(service PollenService) int {
+	for {
+		if counted, ok := service.(interface{ minDatapoints() int }); ok {
+			return counted.minDatapoints()
+		}
+
+		wrapper, ok := service.(interface{ Unwrap() PollenService })
+		if !ok {
+			return 2
+		}
+		service = wrapper.Unwrap()
+	}
+}
+
+// serviceResult is the outcome of a single service call
+type serviceResult struct {
+	index  int
+	report PollenReport
+	err    *ProviderError
+}
+
+// GetPollenReport calls all services in parallel and returns the first result.
+// If every service fails (or the context is done first) a *MultiProviderError
+// is returned with the failure for each service
+func GetPollenReport(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
 
 	//	Start the service segment
 	ctx, seg := xray.BeginSubsegment(ctx, "pollen-report")
 	defer seg.Close(nil)
 
+	start := time.Now()
+	ch := callServices(ctx, services, zipcode)
+
+	//	Track the failures in the order the services were passed
+	failures := make([]*ProviderError, len(services))
+
+collect:
+	for pending := len(services); pending > 0; pending-- {
+		select {
+		case result := <-ch:
+			//	Return the first valid result
+			if result.err == nil {
+				return result.report, nil
+			}
+			failures[result.index] = result.err
+
+		case <-ctx.Done():
+			//	Anybody we're still waiting on has run out of time
+			timeoutPending(failures, nil, services, start, ctx.Err())
+			break collect
+		}
+	}
+
+	apperr := &MultiProviderError{Zipcode: zipcode, Errors: failures}
+	seg.AddError(apperr)
+
+	return PollenReport{}, apperr
+}
+
+// callServices calls each service in its own goroutine and returns the channel
+// the results are sent on.  The channel is buffered so that services finishing
+// after the caller has stopped listening don't block forever
+func callServices(ctx context.Context, services []PollenService, zipcode string) <-chan serviceResult {
+	ch := make(chan serviceResult, len(services))
+
 	//	For each passed service ...
-	for _, service := range services {
+	for index, service := range services {
 
 		//	Launch a goroutine for each service...
-		go func(c context.Context, s PollenService, zip string) {
-
-			//	Get its pollen report ...
-			result, err := s.GetPollenReport(c, zip)
-
-			//	As long as we don't have an error, return what we found on the result channel
-			if err == nil {
-				
-				//	Make sure we also have more than one datapoint!
-				if len(result.Data) > 1 {
-					select {
-					case ch <- result:
-					default:
-					}
-				}				
+		go func(c context.Context, i int, s PollenService, zip string) {
+			ch <- callService(c, i, s, zip)
+		}(ctx, index, service, zipcode)
+
+	}
+
+	return ch
+}
+
+// timeoutPending fills in a timeout error for each service we're still waiting on
+// (the ones without a failure that haven't been marked as succeeded)
+func timeoutPending(failures []*ProviderError, succeeded []bool, services []PollenService, start time.Time, err error) {
+	for index, service := range services {
+		if failures[index] == nil && (succeeded == nil || !succeeded[index]) {
+			failures[index] = &ProviderError{
+				Service: ServiceName(service),
+				Reason:  FailureTimeout,
+				Latency: time.Since(start),
+				Err:     err,
 			}
-		}(ctx, service, zipcode)
+		}
+	}
+}
+
+// getReportSafely gets the pollen report from the service, turning a panic
+// into a provider error -- so one broken service (or response) can't take down
+// the whole process.  The stack is recorded in X-Ray
+func getReportSafely(ctx context.Context, service PollenService, zipcode string) (report PollenReport, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			name := ServiceName(service)
+			xray.AddMetadata(ctx, name+"Panic", string(debug.Stack()))
+
+			report = PollenReport{}
+			err = &ProviderError{Service: name, Reason: FailurePanic, Err: fmt.Errorf("The service panicked: %v", recovered)}
+		}
+	}()
+
+	return service.GetPollenReport(ctx, zipcode)
+}
+
+// callService gets the pollen report from a single service and checks that it's usable
+func callService(ctx context.Context, index int, service PollenService, zipcode string) serviceResult {
+	start := time.Now()
+	name := ServiceName(service)
 
+	//	Get its pollen report ...
+	result, err := getReportSafely(ctx, service, zipcode)
+	latency := time.Since(start)
+
+	if err != nil {
+		perr := newProviderError(name, err, latency)
+
+		//	If we ran out of time, say so (rather than blaming the network)
+		if ctx.Err() != nil && (perr.Reason == FailureTransport || perr.Reason == FailureUnknown) {
+			perr.Reason = FailureTimeout
+		}
+
+		return serviceResult{index: index, err: perr}
 	}
 
-	//	Return the first result passed on the channel
-	return <-ch
+	//	Make sure we also have more than one datapoint (unless the service needs fewer)!
+	if len(result.Data) < minDatapoints(service) {
+		return serviceResult{index: index, err: &ProviderError{
+			Service: name,
+			Reason:  FailureInsufficientData,
+			Latency: latency,
+			Err:     fmt.Errorf("Only %d datapoint(s) were returned", len(result.Data)),
+		}}
+	}
+
+	//	Put the data on the canonical scale, so reports from any service line up
+	result = normalizeReport(result, ServiceScale(service))
+
+	return serviceResult{index: index, report: result}
 }
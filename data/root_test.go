@@ -2,17 +2,66 @@ package data_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/danesparza/pollen/data"
 )
 
+// fakeService is a PollenService that returns a canned report (or error) after a delay
+type fakeService struct {
+	name   string
+	delay  time.Duration
+	report data.PollenReport
+	err    error
+}
+
+func (s fakeService) Name() string {
+	return s.name
+}
+
+func (s fakeService) GetPollenReport(ctx context.Context, zipcode string) (data.PollenReport, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return data.PollenReport{}, ctx.Err()
+	}
+
+	return s.report, s.err
+}
+
+// panickingService is a PollenService that panics (like indexing past the end
+// of a short response would)
+type panickingService struct{}
+
+func (s panickingService) Name() string {
+	return "Panicking"
+}
+
+func (s panickingService) GetPollenReport(ctx context.Context, zipcode string) (data.PollenReport, error) {
+	periods := []float64{}
+	return data.PollenReport{Data: []float64{periods[1]}}, nil
+}
+
 func TestMultipleServices_GetPollenData_ReturnsValidData(t *testing.T) {
 	//	Arrange
+	nasacort := newFixtureServer(t, map[string]fixture{
+		nasacortPath: {file: "nasacort/30019.json"},
+	})
+	defer nasacort.Close()
+
+	pollencom := newFixtureServer(t, map[string]fixture{
+		pollencomForecastPath: {file: "pollencom/forecast_30019.json"},
+		pollencomCurrentPath:  {file: "pollencom/current_30019.json"},
+	})
+	defer pollencom.Close()
+
 	services := []data.PollenService{
-		data.NasacortService{},
-		data.PollencomService{},
+		data.NasacortService{Client: nasacort.Client(), BaseURL: nasacort.URL},
+		data.PollencomService{Client: pollencom.Client(), BaseURL: pollencom.URL},
 	}
 	zipcode := "30019"
 	ctx := context.Background()
@@ -20,9 +69,137 @@ func TestMultipleServices_GetPollenData_ReturnsValidData(t *testing.T) {
 	defer seg.Close(nil)
 
 	//	Act
-	response := data.GetPollenReport(ctx, services, zipcode)
+	response, err := data.GetPollenReport(ctx, services, zipcode)
 
 	//	Assert
+	if err != nil {
+		t.Fatalf("Error calling GetPollenReport: %v", err)
+	}
+
+	if len(response.Data) < 2 {
+		t.Errorf("Expected at least 2 datapoints, but got %v", response.Data)
+	}
+
 	t.Logf("Returned object: %+v", response)
 
 }
+
+func TestMultipleServices_GetPollenData_ReturnsFirstValidReport(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "Broken", err: errors.New("broken")},
+		fakeService{name: "Slow", delay: 500 * time.Millisecond, report: data.PollenReport{ReportingService: "Slow", Data: []float64{1, 2}}},
+		fakeService{name: "Fast", delay: 10 * time.Millisecond, report: data.PollenReport{ReportingService: "Fast", Data: []float64{3, 4}}},
+	}
+	ctx := context.Background()
+	ctx, seg := xray.BeginSegment(ctx, "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := data.GetPollenReport(ctx, services, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("GetPollenReport returned an unexpected error: %v", err)
+	}
+
+	if response.ReportingService != "Fast" {
+		t.Errorf("Expected the report from 'Fast', but got it from '%s'", response.ReportingService)
+	}
+}
+
+func TestMultipleServices_GetPollenData_AllServicesFail_ReturnsMultiProviderError(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "Transport", err: &data.ProviderError{Reason: data.FailureTransport, Err: errors.New("connection refused")}},
+		fakeService{name: "Status", err: &data.ProviderError{Reason: data.FailureHTTPStatus, StatusCode: 503}},
+		fakeService{name: "Short", report: data.PollenReport{Data: []float64{1}}},
+	}
+	ctx := context.Background()
+	ctx, seg := xray.BeginSegment(ctx, "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := data.GetPollenReport(ctx, services, "30019")
+
+	//	Assert
+	merr, ok := err.(*data.MultiProviderError)
+	if !ok {
+		t.Fatalf("Expected a *data.MultiProviderError, but got %T (%v)", err, err)
+	}
+
+	expected := []struct {
+		service string
+		reason  data.FailureReason
+	}{
+		{"Transport", data.FailureTransport},
+		{"Status", data.FailureHTTPStatus},
+		{"Short", data.FailureInsufficientData},
+	}
+
+	if len(merr.Errors) != len(expected) {
+		t.Fatalf("Expected %d provider errors, but got %d", len(expected), len(merr.Errors))
+	}
+
+	for i, e := range expected {
+		if merr.Errors[i].Service != e.service || merr.Errors[i].Reason != e.reason {
+			t.Errorf("Expected error %d to be %s/%s, but got %s/%s", i, e.service, e.reason, merr.Errors[i].Service, merr.Errors[i].Reason)
+		}
+	}
+
+	t.Logf("Returned error: %v", err)
+}
+
+func TestMultipleServices_GetPollenData_RespectsContextDeadline(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "Hung", delay: time.Minute, report: data.PollenReport{Data: []float64{1, 2}}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ctx, seg := xray.BeginSegment(ctx, "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	start := time.Now()
+	_, err := data.GetPollenReport(ctx, services, "30019")
+
+	//	Assert
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("GetPollenReport didn't return when the context deadline passed")
+	}
+
+	merr, ok := err.(*data.MultiProviderError)
+	if !ok {
+		t.Fatalf("Expected a *data.MultiProviderError, but got %T (%v)", err, err)
+	}
+
+	if len(merr.Errors) != 1 || merr.Errors[0].Reason != data.FailureTimeout {
+		t.Errorf("Expected a single timeout error, but got %v", err)
+	}
+}
+
+func TestMultipleServices_GetPollenData_RecoversFromPanics(t *testing.T) {
+	//	Arrange
+	healthy := fakeService{name: "Healthy", delay: 10 * time.Millisecond, report: data.PollenReport{ReportingService: "Healthy", Data: []float64{1, 2}}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	report, err := data.GetPollenReport(ctx, []data.PollenService{panickingService{}, healthy}, "30019")
+	_, panicErr := data.GetPollenReport(ctx, []data.PollenService{panickingService{}}, "30019")
+
+	//	Assert
+	if err != nil || report.ReportingService != "Healthy" {
+		t.Errorf("Expected the healthy service to answer, but got %+v (%v)", report, err)
+	}
+
+	merr, ok := panicErr.(*data.MultiProviderError)
+	if !ok || len(merr.Errors) != 1 || merr.Errors[0].Reason != data.FailurePanic || merr.Errors[0].Service != "Panicking" {
+		t.Fatalf("Expected a panic failure, but got %T (%v)", panicErr, panicErr)
+	}
+
+	if !strings.Contains(merr.Errors[0].Error(), "index out of range") {
+		t.Errorf("Expected the panic in the error, but got %v", merr.Errors[0])
+	}
+}
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// S3ReportStore archives reports in an S3 bucket (or an S3 compatible store,
+// like MinIO), as one JSON object for each zipcode and day:
+// {Prefix}/{zipcode}/{date}.json.  Objects are addressed path-style, so any
+// endpoint works without DNS for the bucket
+type S3ReportStore struct {
+	Bucket string // The bucket name
+	Prefix string // The key prefix for the archived reports (optional)
+
+	client *s3.S3
+}
+
+// NewS3ReportStore returns a store for the bucket.  The configs can set the
+// region, credentials and endpoint (like http://localhost:9000 for MinIO)
+func NewS3ReportStore(bucket, prefix string, configs ...*aws.Config) (*S3ReportStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("There was a problem creating the S3 report store: no bucket")
+	}
+
+	sess, err := session.NewSession(configs...)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem creating the S3 session: %v", err)
+	}
+
+	//	S3 compatible stores don't care about the region, but the signer does
+	config := aws.NewConfig().WithS3ForcePathStyle(true)
+	if aws.StringValue(sess.Config.Region) == "" {
+		config.Region = aws.String("us-east-1")
+	}
+
+	client := s3.New(sess, config)
+	xray.AWS(client.Client)
+
+	return &S3ReportStore{Bucket: bucket, Prefix: prefix, client: client}, nil
+}
+
+// SaveReport archives the report, unless there's already one for its zipcode
+// and date.  The put is conditional too, so stores that support it never
+// replace a report another container saved first
+func (s *S3ReportStore) SaveReport(ctx context.Context, archived ArchivedReport) (bool, error) {
+	key, err := s.key(archived.Zipcode, archived.Date)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := s.exists(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	contents, err := json.Marshal(archived)
+	if err != nil {
+		return false, fmt.Errorf("There was a problem encoding the archived report: %v", err)
+	}
+
+	_, err = s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(contents),
+		ContentType: aws.String("application/json"),
+	}, ifNoneMatch)
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusPreconditionFailed {
+		//	Another container already archived the report
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("There was a problem putting '%s' in S3: %v", key, err)
+	}
+
+	return true, nil
+}
+
+// LoadReport gets the archived report for the zipcode and date
+func (s *S3ReportStore) LoadReport(ctx context.Context, zipcode, date string) (ArchivedReport, bool, error) {
+	key, err := s.key(zipcode, date)
+	if err != nil {
+		return ArchivedReport{}, false, err
+	}
+
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusNotFound {
+		return ArchivedReport{}, false, nil
+	}
+	if err != nil {
+		return ArchivedReport{}, false, fmt.Errorf("There was a problem getting '%s' from S3: %v", key, err)
+	}
+	defer output.Body.Close()
+
+	contents, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return ArchivedReport{}, false, fmt.Errorf("There was a problem reading '%s' from S3: %v", key, err)
+	}
+
+	archived := ArchivedReport{}
+	if err := json.Unmarshal(contents, &archived); err != nil {
+		return ArchivedReport{}, false, fmt.Errorf("There was a problem decoding '%s' from S3: %v", key, err)
+	}
+
+	return archived, true, nil
+}
+
+// exists returns true if the bucket has an object for the key
+func (s *S3ReportStore) exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("There was a problem checking for '%s' in S3: %v", key, err)
+	}
+
+	return true, nil
+}
+
+// key returns the object key for the zipcode and date
+func (s *S3ReportStore) key(zipcode, date string) (string, error) {
+	relative, err := archivePath(zipcode, date)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(s.Prefix, relative), nil
+}
+
+// ifNoneMatch makes a put conditional, so it fails (with a 412) if the object
+// already exists.  The SDK's PutObjectInput doesn't have the header
+func ifNoneMatch(req *request.Request) {
+	req.HTTPRequest.Header.Set("If-None-Match", "*")
+}
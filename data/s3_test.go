@@ -0,0 +1,172 @@
+package data_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// fakeS3 is just enough of the S3 API (path-style HEAD, GET and conditional
+// PUT of objects) to test the store without an S3 compatible server
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (f *fakeS3) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256") || !strings.HasPrefix(req.URL.Path, "/pollen-test/") {
+		rw.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(rw, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		return
+	}
+
+	object, ok := f.objects[req.URL.Path]
+	switch req.Method {
+	case "HEAD", "GET":
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			if req.Method == "GET" {
+				fmt.Fprint(rw, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		if req.Method == "GET" {
+			rw.Write(object)
+		}
+
+	case "PUT":
+		if ok && req.Header.Get("If-None-Match") == "*" {
+			rw.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(rw, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		f.objects[req.URL.Path], _ = ioutil.ReadAll(req.Body)
+		f.puts++
+	}
+}
+
+// newTestS3ReportStore returns a store for the endpoint with fake credentials
+func newTestS3ReportStore(t *testing.T, bucket, endpoint string) *data.S3ReportStore {
+	t.Helper()
+
+	store, err := data.NewS3ReportStore(bucket, "archive", &aws.Config{
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("local", "local", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	if err != nil {
+		t.Fatalf("Unable to create the store: %v", err)
+	}
+
+	return store
+}
+
+func TestS3ReportStore_SaveReport_SavesOncePerDay(t *testing.T) {
+	//	Arrange
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := newTestS3ReportStore(t, "pollen-test", server.URL)
+	first := data.ArchivedReport{Zipcode: "30019", Date: "2019-04-18", Report: data.PollenReport{Data: []float64{9.1}}}
+	second := data.ArchivedReport{Zipcode: "30019", Date: "2019-04-18", Report: data.PollenReport{Data: []float64{1}}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, missing, missingErr := store.LoadReport(ctx, "30019", "2019-04-18")
+	firstSaved, firstErr := store.SaveReport(ctx, first)
+	secondSaved, secondErr := store.SaveReport(ctx, second)
+	archived, ok, err := store.LoadReport(ctx, "30019", "2019-04-18")
+
+	//	Assert
+	if missing || missingErr != nil {
+		t.Errorf("Expected no report before it's saved, but got %v (%v)", missing, missingErr)
+	}
+
+	if !firstSaved || firstErr != nil || secondSaved || secondErr != nil || fake.puts != 1 {
+		t.Errorf("Expected only the first report to be saved, but got %v (%v) and %v (%v) after %d puts", firstSaved, firstErr, secondSaved, secondErr, fake.puts)
+	}
+
+	if _, ok := fake.objects["/pollen-test/archive/30019/2019-04-18.json"]; !ok {
+		t.Errorf("Expected the report to be kept under its zipcode and date, but got %v", fake.objects)
+	}
+
+	if err != nil || !ok || archived.Report.Data[0] != 9.1 {
+		t.Errorf("Expected the first report, but got %+v, %v (%v)", archived, ok, err)
+	}
+}
+
+func TestS3ReportStore_LoadReport_ReturnsServiceErrors(t *testing.T) {
+	//	Arrange
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	store := newTestS3ReportStore(t, "missing-bucket", server.URL)
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, ok, err := store.LoadReport(ctx, "30019", "2019-04-18")
+
+	//	Assert
+	if err == nil || ok || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Expected an access denied error, but got %v (%v)", ok, err)
+	}
+}
+
+func TestS3ReportStore_S3Compatible(t *testing.T) {
+	//	This needs an S3 compatible server with the bucket already created (like
+	//	'docker run -p 9000:9000 minio/minio server /data'), and its credentials
+	//	in the usual AWS environment variables
+	endpoint := os.Getenv("POLLEN_S3_ENDPOINT")
+	bucket := os.Getenv("POLLEN_ARCHIVE_BUCKET")
+	if endpoint == "" || bucket == "" {
+		t.Skip("Set POLLEN_S3_ENDPOINT and POLLEN_ARCHIVE_BUCKET to test against an S3 compatible server")
+	}
+
+	//	Arrange
+	store, err := data.NewS3ReportStore(bucket, fmt.Sprintf("pollen-test-%d", time.Now().UnixNano()), &aws.Config{
+		Endpoint: aws.String(endpoint),
+		Region:   aws.String("us-east-1"),
+	})
+	if err != nil {
+		t.Fatalf("Unable to create the store: %v", err)
+	}
+
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	archived := data.ArchivedReport{Zipcode: "30019", Date: "2019-04-18", Report: data.PollenReport{Data: []float64{9.1}}}
+
+	//	Act
+	saved, saveErr := store.SaveReport(ctx, archived)
+	again, againErr := store.SaveReport(ctx, archived)
+	got, ok, err := store.LoadReport(ctx, "30019", "2019-04-18")
+
+	//	Assert
+	if !saved || saveErr != nil || again || againErr != nil {
+		t.Fatalf("Expected the report to be saved once, but got %v (%v) and %v (%v)", saved, saveErr, again, againErr)
+	}
+
+	if err != nil || !ok || got.Report.Data[0] != 9.1 {
+		t.Errorf("Expected the report, but got %+v, %v (%v)", got, ok, err)
+	}
+}
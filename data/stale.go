@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// DefaultRefreshTimeout is how long a background refresh can take when the
+// fallback doesn't set a timeout
+const DefaultRefreshTimeout = 10 * time.Second
+
+// StaleFallback keeps the last good report for each zipcode, and serves it
+// (marked stale, with the time it was fetched) when every service fails.  While
+// a zipcode is stale, requests get the stale report right away (instead of
+// waiting on the services again) and the report is refreshed in the background
+type StaleFallback struct {
+	Store          CacheStore       // Where the last good reports are kept
+	RefreshTimeout time.Duration    // How long a background refresh can take (optional -- defaults to DefaultRefreshTimeout)
+	Now            func() time.Time // The current time (optional -- defaults to time.Now)
+
+	mu         sync.Mutex
+	refreshing map[string]bool // The keys being refreshed in the background
+}
+
+// Strategy returns the strategy with the fallback
+func (f *StaleFallback) Strategy(strategy Strategy) Strategy {
+	return staleStrategy{fallback: f, strategy: strategy}
+}
+
+// staleStrategy is a strategy with a stale fallback
+type staleStrategy struct {
+	fallback *StaleFallback
+	strategy Strategy
+}
+
+// Unwrap returns the strategy with the fallback
+func (s staleStrategy) Unwrap() Strategy {
+	return s.strategy
+}
+
+// Aggregate gets the report with the strategy, or the last good report if every
+// service fails
+func (s staleStrategy) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	f := s.fallback
+	key := fmt.Sprintf("%s|%s", strategyNamespace(s.strategy, services), NormalizeZipcode(zipcode))
+	fetch := func(ctx context.Context) (PollenReport, error) {
+		return s.strategy.Aggregate(ctx, services, zipcode)
+	}
+
+	entry, ok, err := f.Store.Get(ctx, key)
+	if err != nil {
+		xray.AddMetadata(ctx, "StaleFallbackError", err.Error())
+		ok = false
+	}
+
+	//	If the services were down last time, don't wait on them again
+	if ok && entry.Failed {
+		f.refresh(key, fetch)
+		return staleReport(ctx, entry), nil
+	}
+
+	report, err := fetch(ctx)
+	if err == nil {
+		//	Cached reports were already kept when they were fetched
+		if !report.CacheHit {
+			f.set(ctx, key, CacheEntry{Report: report, FetchedAt: f.now()})
+		}
+		return report, nil
+	}
+
+	//	Only fall back if every service failed (and we have something to fall back to)
+	if _, allFailed := err.(*MultiProviderError); !allFailed || !ok {
+		return report, err
+	}
+
+	entry.Failed = true
+	f.set(ctx, key, entry)
+
+	return staleReport(ctx, entry), nil
+}
+
+// refresh gets the report in the background (unless it's already being
+// refreshed), and keeps it if the services came through
+func (f *StaleFallback) refresh(key string, fetch func(context.Context) (PollenReport, error)) {
+	f.mu.Lock()
+	if f.refreshing == nil {
+		f.refreshing = map[string]bool{}
+	}
+	if f.refreshing[key] {
+		f.mu.Unlock()
+		return
+	}
+	f.refreshing[key] = true
+	f.mu.Unlock()
+
+	timeout := f.RefreshTimeout
+	if timeout <= 0 {
+		timeout = DefaultRefreshTimeout
+	}
+
+	go func() {
+		defer func() {
+			f.mu.Lock()
+			delete(f.refreshing, key)
+			f.mu.Unlock()
+		}()
+
+		//	The request is over by the time this finishes, so it gets its own context
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		ctx, seg := xray.BeginSegment(ctx, "pollen-refresh")
+		report, err := fetch(ctx)
+		seg.Close(err)
+
+		if err == nil {
+			f.set(ctx, key, CacheEntry{Report: report, FetchedAt: f.now()})
+		}
+	}()
+}
+
+// set stores the entry (a problem with the store isn't a problem with the report)
+func (f *StaleFallback) set(ctx context.Context, key string, entry CacheEntry) {
+	if err := f.Store.Set(ctx, key, entry); err != nil {
+		xray.AddMetadata(ctx, "StaleFallbackError", err.Error())
+	}
+}
+
+// now returns the current time
+func (f *StaleFallback) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+
+	return time.Now()
+}
+
+// staleReport returns the entry's report, marked stale
+func staleReport(ctx context.Context, entry CacheEntry) PollenReport {
+	xray.AddAnnotation(ctx, "stale", true)
+
+	fetched := entry.FetchedAt
+	report := entry.Report
+	report.Stale = true
+	report.FetchedAt = &fetched
+	report.CacheHit = false
+
+	return report
+}
+
+// FileStore keeps cached reports as JSON files in a directory, so they last
+// across restarts (or, in /tmp, as long as a Lambda container)
+type FileStore struct {
+	Dir string // The directory for the files (optional -- defaults to a 'pollen' directory in the temp directory)
+}
+
+// Get gets the entry for the key
+func (s FileStore) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	contents, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, fmt.Errorf("There was a problem reading the cached report: %v", err)
+	}
+
+	entry := CacheEntry{}
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return CacheEntry{}, false, fmt.Errorf("There was a problem decoding the cached report: %v", err)
+	}
+
+	return entry, true, nil
+}
+
+// Set stores the entry for the key.  The file is replaced all at once, so a
+// reader never sees half of it
+func (s FileStore) Set(ctx context.Context, key string, entry CacheEntry) error {
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding the cached report: %v", err)
+	}
+
+	if err := os.MkdirAll(s.dir(), 0755); err != nil {
+		return fmt.Errorf("There was a problem creating the cache directory: %v", err)
+	}
+
+	temp, err := ioutil.TempFile(s.dir(), ".report-")
+	if err != nil {
+		return fmt.Errorf("There was a problem writing the cached report: %v", err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(contents)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("There was a problem writing the cached report: %v", err)
+	}
+
+	if err := os.Rename(temp.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("There was a problem writing the cached report: %v", err)
+	}
+
+	return nil
+}
+
+// dir returns the directory for the files
+func (s FileStore) dir() string {
+	if s.Dir != "" {
+		return s.Dir
+	}
+
+	return filepath.Join(os.TempDir(), "pollen")
+}
+
+// path returns the file for the key.  Keys can have any characters, so the
+// file is named for a hash of the key
+func (s FileStore) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir(), hex.EncodeToString(hash[:])+".json")
+}
@@ -0,0 +1,189 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// switchService is a PollenService that can be taken down (and brought back up)
+type switchService struct {
+	down  *int32
+	calls *int32
+	index *int32
+}
+
+func (s switchService) Name() string {
+	return "Switch"
+}
+
+func (s switchService) GetPollenReport(ctx context.Context, zipcode string) (data.PollenReport, error) {
+	atomic.AddInt32(s.calls, 1)
+	if atomic.LoadInt32(s.down) == 1 {
+		return data.PollenReport{}, errors.New("down")
+	}
+
+	index := float64(atomic.LoadInt32(s.index))
+	return data.PollenReport{Zipcode: zipcode, ReportingService: "Switch", Data: []float64{index, index}}, nil
+}
+
+func TestStaleFallback_ServicesDown_ReturnsLastGoodReport(t *testing.T) {
+	//	Arrange
+	var down, calls int32
+	index := int32(7)
+	fetched := time.Date(2019, 4, 18, 16, 0, 0, 0, time.UTC)
+	now := &clock{now: fetched}
+	fallback := &data.StaleFallback{Store: &data.MemoryStore{}, Now: now.Now}
+	strategy := fallback.Strategy(data.FirstResponse{})
+	services := []data.PollenService{switchService{down: &down, calls: &calls, index: &index}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	live, liveErr := strategy.Aggregate(ctx, services, "30019")
+	atomic.StoreInt32(&down, 1)
+	now.now = fetched.Add(time.Hour)
+	stale, staleErr := strategy.Aggregate(ctx, services, "30019-1234")
+
+	//	Assert
+	if liveErr != nil || live.Stale || live.FetchedAt != nil {
+		t.Errorf("Expected the live report, but got %+v (%v)", live, liveErr)
+	}
+
+	if staleErr != nil || !stale.Stale || stale.Data[0] != 7 {
+		t.Fatalf("Expected the last good report, but got %+v (%v)", stale, staleErr)
+	}
+
+	if stale.FetchedAt == nil || !stale.FetchedAt.Equal(fetched) {
+		t.Errorf("Expected the stale report to have its original fetch time %v, but got %v", fetched, stale.FetchedAt)
+	}
+}
+
+func TestStaleFallback_NoLastGoodReport_ReturnsError(t *testing.T) {
+	//	Arrange
+	down, calls, index := int32(1), int32(0), int32(0)
+	fallback := &data.StaleFallback{Store: &data.MemoryStore{}}
+	services := []data.PollenService{switchService{down: &down, calls: &calls, index: &index}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := fallback.Strategy(data.FirstResponse{}).Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if _, ok := err.(*data.MultiProviderError); !ok {
+		t.Errorf("Expected a *data.MultiProviderError, but got %T (%v)", err, err)
+	}
+}
+
+func TestStaleFallback_WhileStale_RefreshesInBackground(t *testing.T) {
+	//	Arrange
+	var down, calls int32
+	index := int32(7)
+	fallback := &data.StaleFallback{Store: &data.MemoryStore{}}
+	strategy := fallback.Strategy(data.FirstResponse{})
+	services := []data.PollenService{switchService{down: &down, calls: &calls, index: &index}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	strategy.Aggregate(ctx, services, "30019")
+	atomic.StoreInt32(&down, 1)
+	strategy.Aggregate(ctx, services, "30019")
+
+	//	Act -- the services are back, but the next request doesn't wait on them
+	atomic.StoreInt32(&down, 0)
+	atomic.StoreInt32(&index, 3)
+	next, err := strategy.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil || !next.Stale || next.Data[0] != 7 {
+		t.Fatalf("Expected the stale report right away, but got %+v (%v)", next, err)
+	}
+
+	//	Once the refresh finishes, requests get the fresh report again
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		report, err := strategy.Aggregate(ctx, services, "30019")
+		if err == nil && !report.Stale {
+			if report.Data[0] != 3 {
+				t.Errorf("Expected the refreshed report, but got %+v", report)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the report to be refreshed in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFileStore_SetAndGet_PersistsEntries(t *testing.T) {
+	//	Arrange
+	dir, err := ioutil.TempDir("", "pollen-test")
+	if err != nil {
+		t.Fatalf("Unable to create a temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fetched := time.Date(2019, 4, 18, 16, 0, 0, 0, time.UTC)
+	entry := data.CacheEntry{Report: data.PollenReport{Zipcode: "30019", Data: []float64{9.1}}, FetchedAt: fetched, Failed: true}
+	ctx := context.Background()
+
+	//	Act
+	_, missing, missingErr := data.FileStore{Dir: dir}.Get(ctx, "First|30019")
+	setErr := data.FileStore{Dir: dir}.Set(ctx, "First|30019", entry)
+	got, ok, err := data.FileStore{Dir: dir}.Get(ctx, "First|30019")
+
+	//	Assert
+	if missing || missingErr != nil {
+		t.Errorf("Expected no entry before it's set, but got %v (%v)", missing, missingErr)
+	}
+
+	if setErr != nil || err != nil || !ok {
+		t.Fatalf("Expected the entry, but got %v (%v, %v)", ok, setErr, err)
+	}
+
+	if got.Report.Data[0] != 9.1 || !got.FetchedAt.Equal(fetched) || !got.Failed {
+		t.Errorf("Unexpected entry: %+v", got)
+	}
+}
+
+func TestStaleFallback_FileStore_ServesLastGoodReportAfterRestart(t *testing.T) {
+	//	Arrange
+	dir, err := ioutil.TempDir("", "pollen-test")
+	if err != nil {
+		t.Fatalf("Unable to create a temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var down, calls int32
+	index := int32(7)
+	services := []data.PollenService{switchService{down: &down, calls: &calls, index: &index}}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Each start gets its own in-memory cache (and fallback)
+	start := func() data.Strategy {
+		cache := data.Cache{Store: &data.MemoryStore{}}
+		fallback := &data.StaleFallback{Store: data.FileStore{Dir: dir}}
+		return fallback.Strategy(cache.Strategy(data.FirstResponse{}))
+	}
+
+	//	Act
+	start().Aggregate(ctx, services, "30019")
+	atomic.StoreInt32(&down, 1)
+	stale, err := start().Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil || !stale.Stale || stale.Data[0] != 7 {
+		t.Errorf("Expected the last good report from before the restart, but got %+v (%v)", stale, err)
+	}
+}
@@ -0,0 +1,314 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// Strategy decides how the reports from multiple services are combined into one
+type Strategy interface {
+	// Aggregate gets the pollen report for the zipcode from the services
+	Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error)
+}
+
+// Strategy names
+const (
+	// StrategyFirst returns the first valid report
+	StrategyFirst = "first"
+
+	// StrategyConsensus merges the reports from all services
+	StrategyConsensus = "consensus"
+
+	// StrategyHedged calls the services a priority tier at a time, and returns the first valid report
+	StrategyHedged = "hedged"
+)
+
+// DefaultConsensusTimeout is how long the consensus strategy waits on the services by default
+const DefaultConsensusTimeout = 5 * time.Second
+
+// NewStrategy returns the strategy with the given name (StrategyFirst,
+// StrategyConsensus or StrategyHedged).  A blank name is the same as
+// StrategyFirst.  The merge method is only used by the consensus strategy
+func NewStrategy(name string, method MergeMethod) (Strategy, error) {
+	switch strings.ToLower(name) {
+	case "", StrategyFirst:
+		return FirstResponse{}, nil
+	case StrategyConsensus:
+		consensus := Consensus{Method: method}
+		if _, err := consensus.mergeFunc(); err != nil {
+			return nil, err
+		}
+		return consensus, nil
+	case StrategyHedged:
+		return Hedged{}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown aggregation strategy '%s'", name)
+}
+
+// FirstResponse is the strategy that calls all services in parallel and
+// returns the first valid report.  It's the default
+type FirstResponse struct{}
+
+// Aggregate gets the first valid pollen report
+func (s FirstResponse) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	return GetPollenReport(ctx, services, zipcode)
+}
+
+// MergeMethod is how the indices for a single day are combined
+type MergeMethod string
+
+const (
+	// MergeMedian uses the median of the indices (the default)
+	MergeMedian MergeMethod = "median"
+
+	// MergeMean uses the mean of the indices
+	MergeMean MergeMethod = "mean"
+)
+
+// ConsensusService is the reporting service name used for merged reports
+const ConsensusService = "Consensus"
+
+// Consensus is the strategy that waits for every service (up to the context
+// deadline or Timeout, whichever comes first) and merges their reports date by date
+type Consensus struct {
+	Method  MergeMethod   // How to merge the indices for a day (optional -- defaults to MergeMedian)
+	Timeout time.Duration // How long to wait for the services (optional -- defaults to the context deadline)
+}
+
+// Aggregate gets the reports from all services and merges them
+func (s Consensus) Aggregate(ctx context.Context, services []PollenService, zipcode string) (PollenReport, error) {
+	merge, err := s.mergeFunc()
+	if err != nil {
+		return PollenReport{}, err
+	}
+
+	//	Start the service segment
+	ctx, seg := xray.BeginSubsegment(ctx, "pollen-consensus")
+	defer seg.Close(nil)
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	ch := callServices(ctx, services, zipcode)
+
+	//	Track the reports and failures in the order the services were passed
+	reports := make([]PollenReport, len(services))
+	succeeded := make([]bool, len(services))
+	failures := make([]*ProviderError, len(services))
+
+collect:
+	for pending := len(services); pending > 0; pending-- {
+		select {
+		case result := <-ch:
+			if result.err != nil {
+				failures[result.index] = result.err
+				continue
+			}
+			reports[result.index] = result.report
+			succeeded[result.index] = true
+
+		case <-ctx.Done():
+			//	Anybody we're still waiting on has run out of time
+			timeoutPending(failures, succeeded, services, start, ctx.Err())
+			break collect
+		}
+	}
+
+	//	Gather up what we got
+	valid := []PollenReport{}
+	sources := []SourceReport{}
+	for index, service := range services {
+		if succeeded[index] {
+			valid = append(valid, reports[index])
+			sources = append(sources, SourceReport{
+				Service:           reports[index].ReportingService,
+				PredominantPollen: reports[index].PredominantPollen,
+				Allergens:         reports[index].Allergens,
+				Data:              reports[index].Data,
+				Normalized:        reports[index].Normalized,
+			})
+			continue
+		}
+
+		sources = append(sources, SourceReport{
+			Service: ServiceName(service),
+			Error:   failures[index].Error(),
+		})
+	}
+
+	if len(valid) == 0 {
+		apperr := &MultiProviderError{Zipcode: zipcode, Errors: failures}
+		seg.AddError(apperr)
+		return PollenReport{}, apperr
+	}
+
+	retval := mergeReports(valid, merge)
+	retval.Sources = sources
+
+	xray.AddMetadata(ctx, "ConsensusResult", retval)
+
+	return retval, nil
+}
+
+// mergeFunc returns the function that combines the indices for a single day
+func (s Consensus) mergeFunc() (func([]float64) float64, error) {
+	switch s.Method {
+	case "", MergeMedian:
+		return median, nil
+	case MergeMean:
+		return mean, nil
+	}
+
+	return nil, fmt.Errorf("Unknown merge method '%s'", s.Method)
+}
+
+// mergeReports merges the valid reports (in service order) into a single report.
+// The services may use different native scales, so the merged report is on the
+// canonical scale
+func mergeReports(reports []PollenReport, merge func([]float64) float64) PollenReport {
+	retval := PollenReport{
+		ReportingService: ConsensusService,
+		Zipcode:          reports[0].Zipcode,
+		Location:         reports[0].Location,
+		StartDate:        reports[0].StartDate,
+	}
+
+	//	Line the days up by date (the services don't all start their forecasts on the same day)
+	dates, dated := forecastDates(reports)
+	values := map[string][]float64{}
+	order := []string{}
+	for index, report := range reports {
+		if report.StartDate.Before(retval.StartDate) {
+			retval.StartDate = report.StartDate
+		}
+
+		for day, value := range canonicalData(report) {
+			date := dates[index][day]
+			if _, ok := values[date]; !ok {
+				order = append(order, date)
+			}
+			values[date] = append(values[date], value)
+		}
+	}
+	if dated {
+		sort.Strings(order)
+	}
+
+	//	Merge each day using the services that have data for it
+	for _, date := range order {
+		merged := merge(values[date])
+		retval.Data = append(retval.Data, merged)
+		retval.Disagreement = append(retval.Disagreement, stddev(values[date]))
+
+		if dated {
+			retval.Days = append(retval.Days, newForecastDay(date, merged, CanonicalScale))
+		}
+	}
+	retval.Normalized = retval.Data
+
+	//	Union the predominant pollens (keeping the order we first saw them in)
+	for _, report := range reports {
+		allergens := report.Allergens
+		if len(allergens) == 0 {
+			allergens = ParseAllergens(report.PredominantPollen)
+		}
+		retval.Allergens = mergeAllergens(retval.Allergens, allergens)
+	}
+
+	pollens := []string{}
+	for _, allergen := range retval.Allergens {
+		pollens = append(pollens, allergen.Name)
+	}
+	retval.PredominantPollen = strings.Join(pollens, ", ")
+
+	return retval
+}
+
+// forecastDates returns the date of each day of data in each report.  Days
+// without a date are taken to line up with the first report that has dates.  If
+// none of the reports have dates, the days are keyed by position and dated is false
+func forecastDates(reports []PollenReport) (dates [][]string, dated bool) {
+	start := time.Time{}
+	for _, report := range reports {
+		if len(report.Days) == 0 {
+			continue
+		}
+		if parsed, err := time.Parse(DateFormat, report.Days[0].Date); err == nil {
+			start = parsed
+			break
+		}
+	}
+
+	dates = make([][]string, len(reports))
+	for index, report := range reports {
+		for day := range canonicalData(report) {
+			switch {
+			case day < len(report.Days) && report.Days[day].Date != "":
+				dates[index] = append(dates[index], report.Days[day].Date)
+			case !start.IsZero():
+				dates[index] = append(dates[index], start.AddDate(0, 0, day).Format(DateFormat))
+			default:
+				dates[index] = append(dates[index], strconv.Itoa(day))
+			}
+		}
+	}
+
+	return dates, !start.IsZero()
+}
+
+// canonicalData returns the report data on the canonical scale (if it's been
+// normalized), or the raw data otherwise
+func canonicalData(report PollenReport) []float64 {
+	if len(report.Normalized) == len(report.Data) {
+		return report.Normalized
+	}
+
+	return report.Data
+}
+
+// median returns the median of the values
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
+
+// mean returns the mean of the values
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+
+	return total / float64(len(values))
+}
+
+// stddev returns the (population) standard deviation of the values
+func stddev(values []float64) float64 {
+	average := mean(values)
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - average) * (value - average)
+	}
+
+	return math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,226 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+func TestNewStrategy_ReturnsStrategyByName(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   data.MergeMethod
+		expected data.Strategy
+		wantErr  bool
+	}{
+		{"", "", data.FirstResponse{}, false},
+		{"first", "", data.FirstResponse{}, false},
+		{"Consensus", "", data.Consensus{}, false},
+		{"consensus", data.MergeMean, data.Consensus{Method: data.MergeMean}, false},
+		{"consensus", "mode", nil, true},
+		{"hedged", "", data.Hedged{}, false},
+		{"fastest", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		strategy, err := data.NewStrategy(tt.name, tt.method)
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewStrategy(%q, %q) returned error %v", tt.name, tt.method, err)
+		}
+
+		if !reflect.DeepEqual(strategy, tt.expected) {
+			t.Errorf("NewStrategy(%q, %q) = %#v, expected %#v", tt.name, tt.method, strategy, tt.expected)
+		}
+	}
+}
+
+func TestConsensus_Aggregate_MergesAllServices(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "One", report: data.PollenReport{ReportingService: "One", Zipcode: "30019", Location: "DACULA, GA", PredominantPollen: "Oak, Birch.", Data: []float64{1, 2, 3, 4}}},
+		fakeService{name: "Two", delay: 20 * time.Millisecond, report: data.PollenReport{ReportingService: "Two", Zipcode: "30019", PredominantPollen: "oak, Sycamore", Data: []float64{3, 2, 9}}},
+		fakeService{name: "Three", report: data.PollenReport{ReportingService: "Three", Zipcode: "30019", Data: []float64{2, 2, 6, 8}}},
+		fakeService{name: "Broken", err: errors.New("broken")},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := data.Consensus{}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Aggregate returned an unexpected error: %v", err)
+	}
+
+	if expected := []float64{2, 2, 6, 6}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected median data %v, but got %v", expected, response.Data)
+	}
+
+	if response.Disagreement[1] != 0 || response.Disagreement[2] <= response.Disagreement[0] {
+		t.Errorf("Unexpected disagreement scores: %v", response.Disagreement)
+	}
+
+	if response.PredominantPollen != "Oak, Birch, Sycamore" {
+		t.Errorf("Expected the union of the predominant pollens, but got '%s'", response.PredominantPollen)
+	}
+
+	if response.ReportingService != data.ConsensusService || response.Location != "DACULA, GA" {
+		t.Errorf("Unexpected service or location: %s / %s", response.ReportingService, response.Location)
+	}
+
+	if len(response.Sources) != len(services) {
+		t.Fatalf("Expected a source for each service, but got %d", len(response.Sources))
+	}
+
+	if !reflect.DeepEqual(response.Sources[1].Data, []float64{3, 2, 9}) || response.Sources[3].Error == "" {
+		t.Errorf("Unexpected sources: %+v", response.Sources)
+	}
+}
+
+// scaledFakeService is a fakeService that reports on its own native scale
+type scaledFakeService struct {
+	fakeService
+	scale data.IndexScale
+}
+
+func (s scaledFakeService) Scale() data.IndexScale {
+	return s.scale
+}
+
+func TestConsensus_Aggregate_MergesOnCanonicalScale(t *testing.T) {
+	//	Arrange
+	fiveScale := data.IndexScale{Name: "five", Min: 0, Max: 5, Thresholds: []float64{1, 2, 3, 4}}
+	services := []data.PollenService{
+		fakeService{name: "Twelve", report: data.PollenReport{Data: []float64{2.5, 12}}},
+		scaledFakeService{fakeService{name: "Five", report: data.PollenReport{Data: []float64{1, 5}}}, fiveScale},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := data.Consensus{}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Aggregate returned an unexpected error: %v", err)
+	}
+
+	if expected := []float64{2.5, 12}; !reflect.DeepEqual(response.Data, expected) || !reflect.DeepEqual(response.Normalized, expected) {
+		t.Errorf("Expected data %v, but got %v (normalized %v)", expected, response.Data, response.Normalized)
+	}
+
+	if response.Disagreement[0] != 0 || response.Disagreement[1] != 0 {
+		t.Errorf("Expected the services to agree once normalized, but got %v", response.Disagreement)
+	}
+
+	if !reflect.DeepEqual(response.Sources[1].Data, []float64{1, 5}) || !reflect.DeepEqual(response.Sources[1].Normalized, []float64{2.5, 12}) {
+		t.Errorf("Expected the source to have both raw and normalized data: %+v", response.Sources[1])
+	}
+}
+
+func TestConsensus_Aggregate_Mean(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "One", report: data.PollenReport{Data: []float64{1, 2}}},
+		fakeService{name: "Two", report: data.PollenReport{Data: []float64{2, 2}}},
+		fakeService{name: "Three", report: data.PollenReport{Data: []float64{6, 2}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := data.Consensus{Method: data.MergeMean}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Aggregate returned an unexpected error: %v", err)
+	}
+
+	if expected := []float64{3, 2}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected mean data %v, but got %v", expected, response.Data)
+	}
+}
+
+func TestConsensus_Aggregate_MergesByDate(t *testing.T) {
+	//	Arrange
+	days := func(dates ...string) []data.ForecastDay {
+		retval := []data.ForecastDay{}
+		for _, date := range dates {
+			retval = append(retval, data.ForecastDay{Date: date})
+		}
+		return retval
+	}
+	services := []data.PollenService{
+		fakeService{name: "Today", report: data.PollenReport{Data: []float64{2, 3, 4}, Days: days("2019-04-18", "2019-04-19", "2019-04-20")}},
+		fakeService{name: "Yesterday", report: data.PollenReport{Data: []float64{1, 2, 5, 6}, Days: days("2019-04-17", "2019-04-18", "2019-04-19", "2019-04-20")}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := data.Consensus{Method: data.MergeMean}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Aggregate returned an unexpected error: %v", err)
+	}
+
+	if expected := []float64{1, 2, 4, 5}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected the days to be merged by date into %v, but got %v", expected, response.Data)
+	}
+
+	if len(response.Days) != 4 || response.Days[0].Date != "2019-04-17" || response.Days[3].Date != "2019-04-20" {
+		t.Errorf("Expected the merged days to run from 2019-04-17 to 2019-04-20, but got %+v", response.Days)
+	}
+}
+
+func TestConsensus_Aggregate_StopsWaitingAfterTimeout(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "Fast", report: data.PollenReport{Data: []float64{1, 2}}},
+		fakeService{name: "Hung", delay: time.Minute, report: data.PollenReport{Data: []float64{5, 6}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	response, err := data.Consensus{Timeout: 50 * time.Millisecond}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Aggregate returned an unexpected error: %v", err)
+	}
+
+	if expected := []float64{1, 2}; !reflect.DeepEqual(response.Data, expected) {
+		t.Errorf("Expected data %v, but got %v", expected, response.Data)
+	}
+
+	if response.Sources[1].Error == "" {
+		t.Errorf("Expected the hung service to be reported as failed: %+v", response.Sources[1])
+	}
+}
+
+func TestConsensus_Aggregate_AllServicesFail_ReturnsMultiProviderError(t *testing.T) {
+	//	Arrange
+	services := []data.PollenService{
+		fakeService{name: "Broken", err: errors.New("broken")},
+		fakeService{name: "Short", report: data.PollenReport{Data: []float64{1}}},
+	}
+	ctx, seg := xray.BeginSegment(context.Background(), "unit-test")
+	defer seg.Close(nil)
+
+	//	Act
+	_, err := data.Consensus{}.Aggregate(ctx, services, "30019")
+
+	//	Assert
+	if _, ok := err.(*data.MultiProviderError); !ok {
+		t.Errorf("Expected a *data.MultiProviderError, but got %T (%v)", err, err)
+	}
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"strings"
+	"time"
+)
+
+// stateTimezones maps US state (and territory) abbreviations to the timezone
+// most of the state observes
+var stateTimezones = map[string]string{
+	"AK": "America/Anchorage",
+	"AL": "America/Chicago",
+	"AR": "America/Chicago",
+	"AZ": "America/Phoenix",
+	"CA": "America/Los_Angeles",
+	"CO": "America/Denver",
+	"CT": "America/New_York",
+	"DC": "America/New_York",
+	"DE": "America/New_York",
+	"FL": "America/New_York",
+	"GA": "America/New_York",
+	"HI": "Pacific/Honolulu",
+	"IA": "America/Chicago",
+	"ID": "America/Boise",
+	"IL": "America/Chicago",
+	"IN": "America/Indiana/Indianapolis",
+	"KS": "America/Chicago",
+	"KY": "America/New_York",
+	"LA": "America/Chicago",
+	"MA": "America/New_York",
+	"MD": "America/New_York",
+	"ME": "America/New_York",
+	"MI": "America/Detroit",
+	"MN": "America/Chicago",
+	"MO": "America/Chicago",
+	"MS": "America/Chicago",
+	"MT": "America/Denver",
+	"NC": "America/New_York",
+	"ND": "America/Chicago",
+	"NE": "America/Chicago",
+	"NH": "America/New_York",
+	"NJ": "America/New_York",
+	"NM": "America/Denver",
+	"NV": "America/Los_Angeles",
+	"NY": "America/New_York",
+	"OH": "America/New_York",
+	"OK": "America/Chicago",
+	"OR": "America/Los_Angeles",
+	"PA": "America/New_York",
+	"PR": "America/Puerto_Rico",
+	"RI": "America/New_York",
+	"SC": "America/New_York",
+	"SD": "America/Chicago",
+	"TN": "America/Chicago",
+	"TX": "America/Chicago",
+	"UT": "America/Denver",
+	"VA": "America/New_York",
+	"VT": "America/New_York",
+	"WA": "America/Los_Angeles",
+	"WI": "America/Chicago",
+	"WV": "America/New_York",
+	"WY": "America/Denver",
+}
+
+// countryTimezones maps the ISO codes of countries outside of the US (that
+// have postal codes providers are used for) to the country's timezone
+var countryTimezones = map[string]string{
+	"DE": "Europe/Berlin",
+}
+
+// stateLocation returns the timezone for a US state abbreviation.  If the state
+// isn't known (or the timezone database isn't available) UTC is used
+func stateLocation(state string) *time.Location {
+	name, ok := stateTimezones[strings.ToUpper(strings.TrimSpace(state))]
+	if !ok {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// countryLocation returns the timezone for an ISO country code outside of the
+// US.  If the country isn't known (or the timezone database isn't available)
+// UTC is used
+func countryLocation(country string) *time.Location {
+	name, ok := countryTimezones[strings.ToUpper(strings.TrimSpace(country))]
+	if !ok {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
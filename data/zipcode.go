@@ -0,0 +1,177 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZipcodeCountry is the ISO country code for zipcodes
+const ZipcodeCountry = "US"
+
+// zipcodePrefixes maps ranges of 3 digit zipcode prefixes to the state (or
+// territory) they're in.  The ranges are sorted, and prefixes that aren't in
+// a range (or are military) don't have a state
+var zipcodePrefixes = []struct {
+	first, last int
+	state       string
+}{
+	{5, 5, "NY"},
+	{6, 9, "PR"},
+	{10, 27, "MA"},
+	{28, 29, "RI"},
+	{30, 38, "NH"},
+	{39, 49, "ME"},
+	{50, 54, "VT"},
+	{55, 55, "MA"},
+	{56, 59, "VT"},
+	{60, 69, "CT"},
+	{70, 89, "NJ"},
+	{100, 149, "NY"},
+	{150, 196, "PA"},
+	{197, 199, "DE"},
+	{200, 200, "DC"},
+	{201, 201, "VA"},
+	{202, 205, "DC"},
+	{206, 219, "MD"},
+	{220, 246, "VA"},
+	{247, 268, "WV"},
+	{270, 289, "NC"},
+	{290, 299, "SC"},
+	{300, 319, "GA"},
+	{320, 339, "FL"},
+	{341, 349, "FL"},
+	{350, 369, "AL"},
+	{370, 385, "TN"},
+	{386, 397, "MS"},
+	{398, 399, "GA"},
+	{400, 427, "KY"},
+	{430, 459, "OH"},
+	{460, 479, "IN"},
+	{480, 499, "MI"},
+	{500, 528, "IA"},
+	{530, 549, "WI"},
+	{550, 567, "MN"},
+	{570, 577, "SD"},
+	{580, 588, "ND"},
+	{590, 599, "MT"},
+	{600, 629, "IL"},
+	{630, 658, "MO"},
+	{660, 679, "KS"},
+	{680, 693, "NE"},
+	{700, 715, "LA"},
+	{716, 729, "AR"},
+	{730, 749, "OK"},
+	{750, 799, "TX"},
+	{800, 816, "CO"},
+	{820, 831, "WY"},
+	{832, 838, "ID"},
+	{840, 847, "UT"},
+	{850, 865, "AZ"},
+	{870, 884, "NM"},
+	{885, 885, "TX"},
+	{889, 898, "NV"},
+	{900, 961, "CA"},
+	{967, 968, "HI"},
+	{970, 979, "OR"},
+	{980, 994, "WA"},
+	{995, 999, "AK"},
+}
+
+// NormalizeZipcode returns the 5 digit zipcode (so ' 30019-1234 ' and '30019'
+// are the same zipcode)
+func NormalizeZipcode(zipcode string) string {
+	zipcode = strings.TrimSpace(zipcode)
+	if index := strings.IndexAny(zipcode, "- "); index >= 0 {
+		zipcode = zipcode[:index]
+	}
+
+	return zipcode
+}
+
+// ValidateZipcode returns an error if the zipcode isn't 5 digits (with an
+// optional +4, like '30019-1234')
+func ValidateZipcode(zipcode string) error {
+	trimmed := strings.TrimSpace(zipcode)
+	if trimmed == "" {
+		return fmt.Errorf("A zipcode is required")
+	}
+
+	digits := func(value string) bool {
+		return value != "" && strings.Trim(value, "0123456789") == ""
+	}
+
+	switch {
+	case len(trimmed) == 5 && digits(trimmed):
+	case len(trimmed) == 10 && trimmed[5] == '-' && digits(trimmed[:5]) && digits(trimmed[6:]):
+	default:
+		return fmt.Errorf("'%s' isn't a valid zipcode -- it should be 5 digits", zipcode)
+	}
+
+	return nil
+}
+
+// zipcodeState returns the state abbreviation for the zipcode, or a blank
+// string if it isn't known
+func zipcodeState(zipcode string) string {
+	zipcode = NormalizeZipcode(zipcode)
+	if len(zipcode) != 5 {
+		return ""
+	}
+
+	prefix, err := strconv.Atoi(zipcode[:3])
+	if err != nil {
+		return ""
+	}
+
+	for _, prefixes := range zipcodePrefixes {
+		if prefix >= prefixes.first && prefix <= prefixes.last {
+			return prefixes.state
+		}
+	}
+
+	return ""
+}
+
+// zipcodeLocation returns the timezone for the zipcode (UTC if it isn't known)
+func zipcodeLocation(zipcode string) *time.Location {
+	return stateLocation(zipcodeState(zipcode))
+}
+
+// serviceCountry returns the ISO country code for the postal codes the service
+// is given (ZipcodeCountry unless the service has a CountryCode() method).
+// Wrapped services have the country of the service they wrap
+func serviceCountry(service PollenService) string {
+	for {
+		if located, ok := service.(interface{ CountryCode() string }); ok {
+			return located.CountryCode()
+		}
+
+		wrapper, ok := service.(interface{ Unwrap() PollenService })
+		if !ok {
+			return ZipcodeCountry
+		}
+		service = wrapper.Unwrap()
+	}
+}
+
+// servicesLocation returns the timezone for the zipcode the services are
+// given.  If they're all for the same country outside of the US, it's that
+// country's timezone -- otherwise it's the zipcode's
+func servicesLocation(services []PollenService, zipcode string) *time.Location {
+	country := ""
+	for _, service := range services {
+		next := serviceCountry(service)
+		if country != "" && next != country {
+			return zipcodeLocation(zipcode)
+		}
+		country = next
+	}
+
+	if country == "" || country == ZipcodeCountry {
+		return zipcodeLocation(zipcode)
+	}
+
+	return countryLocation(country)
+}
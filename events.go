@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiGatewayProxyRequest is an API Gateway REST API (v1) proxy integration event
+type apiGatewayProxyRequest struct {
+	Resource                        string              `json:"resource"`
+	Path                            string              `json:"path"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Headers                         map[string]string   `json:"headers"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	PathParameters                  map[string]string   `json:"pathParameters"`
+}
+
+// httpEventResponse is the response to an API Gateway REST API (v1) proxy
+// integration, an API Gateway HTTP API (v2) event or a Lambda Function URL
+// event -- they all expect the same shape
+type httpEventResponse struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// apiGatewayV2HTTPRequest is an API Gateway HTTP API (v2) event.  Lambda
+// Function URL events have the same shape
+type apiGatewayV2HTTPRequest struct {
+	Version               string            `json:"version"`
+	RouteKey              string            `json:"routeKey"`
+	RawPath               string            `json:"rawPath"`
+	RawQueryString        string            `json:"rawQueryString"`
+	Headers               map[string]string `json:"headers"`
+	QueryStringParameters map[string]string `json:"queryStringParameters"`
+	PathParameters        map[string]string `json:"pathParameters"`
+	RequestContext        struct {
+		HTTP struct {
+			Method string `json:"method"`
+			Path   string `json:"path"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// albTargetGroupRequest is an Application Load Balancer target group event.
+// If the target group has multi value headers turned on, the query string and
+// headers come in the multi value fields instead
+type albTargetGroupRequest struct {
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	RequestContext                  struct {
+		ELB struct {
+			TargetGroupArn string `json:"targetGroupArn"`
+		} `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// albTargetGroupResponse is the response to an Application Load Balancer target
+// group event.  It has to use multi value headers if the request did
+type albTargetGroupResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	StatusDescription string              `json:"statusDescription"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// eventShape has just enough of an event to tell what kind of event it is
+type eventShape struct {
+	Version        string `json:"version"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		HTTP *json.RawMessage `json:"http"`
+		ELB  *json.RawMessage `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// proxyRequest is the part of an HTTP event we need, no matter which kind of event it was
+type proxyRequest struct {
+	Method         string
+	Path           string
+	PathParameters map[string]string
+	Query          url.Values
+}
+
+// proxyResponse is the response to an HTTP event, before it's put in the shape
+// the event source expects
+type proxyResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+}
+
+// handleEvent handles a Lambda event.  HTTP events (API Gateway proxy, Function
+// URL and ALB target group events) get a response in the format the event
+// source expects (errors included) -- anything else is treated as a Message
+func handleEvent(ctx context.Context, event json.RawMessage, getReport reportFunc) (interface{}, error) {
+	shape := eventShape{}
+	if err := json.Unmarshal(event, &shape); err != nil {
+		return nil, &requestError{fmt.Errorf("There was a problem decoding the event: %v", err)}
+	}
+
+	switch {
+	//	API Gateway HTTP API (v2) and Function URLs
+	case shape.Version == "2.0" && shape.RequestContext.HTTP != nil:
+		req := apiGatewayV2HTTPRequest{}
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, &requestError{fmt.Errorf("There was a problem decoding the HTTP API event: %v", err)}
+		}
+
+		query, err := url.ParseQuery(req.RawQueryString)
+		if err != nil || len(query) == 0 {
+			query = singleValues(req.QueryStringParameters)
+		}
+
+		path := req.RawPath
+		if path == "" {
+			path = req.RequestContext.HTTP.Path
+		}
+
+		response := serveProxy(ctx, getReport, proxyRequest{
+			Method:         req.RequestContext.HTTP.Method,
+			Path:           path,
+			PathParameters: req.PathParameters,
+			Query:          query,
+		})
+
+		return httpEventResponse{StatusCode: response.StatusCode, Headers: response.Headers, Body: response.Body}, nil
+
+	//	Application Load Balancer target groups
+	case shape.HTTPMethod != "" && shape.RequestContext.ELB != nil:
+		req := albTargetGroupRequest{}
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, &requestError{fmt.Errorf("There was a problem decoding the load balancer event: %v", err)}
+		}
+
+		//	The load balancer passes the query string along without decoding it
+		multiValue := req.MultiValueHeaders != nil || req.MultiValueQueryStringParameters != nil
+		query := url.Values{}
+		for name, values := range req.MultiValueQueryStringParameters {
+			for _, value := range values {
+				query.Add(unescape(name), unescape(value))
+			}
+		}
+		for name, value := range req.QueryStringParameters {
+			query.Add(unescape(name), unescape(value))
+		}
+
+		response := serveProxy(ctx, getReport, proxyRequest{
+			Method: req.HTTPMethod,
+			Path:   req.Path,
+			Query:  query,
+		})
+
+		retval := albTargetGroupResponse{
+			StatusCode:        response.StatusCode,
+			StatusDescription: fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+			Body:              response.Body,
+		}
+
+		if multiValue {
+			retval.MultiValueHeaders = map[string][]string{}
+			for name, value := range response.Headers {
+				retval.MultiValueHeaders[name] = []string{value}
+			}
+		} else {
+			retval.Headers = response.Headers
+		}
+
+		return retval, nil
+
+	//	API Gateway REST API (v1)
+	case shape.HTTPMethod != "" && shape.RequestContext.ELB == nil:
+		req := apiGatewayProxyRequest{}
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, &requestError{fmt.Errorf("There was a problem decoding the API Gateway event: %v", err)}
+		}
+
+		query := url.Values(req.MultiValueQueryStringParameters)
+		if len(query) == 0 {
+			query = singleValues(req.QueryStringParameters)
+		}
+
+		response := serveProxy(ctx, getReport, proxyRequest{
+			Method:         req.HTTPMethod,
+			Path:           req.Path,
+			PathParameters: req.PathParameters,
+			Query:          query,
+		})
+
+		return httpEventResponse{StatusCode: response.StatusCode, Headers: response.Headers, Body: response.Body}, nil
+	}
+
+	//	Otherwise, it's a plain message
+	msg := Message{}
+	if err := json.Unmarshal(event, &msg); err != nil {
+		return nil, &requestError{fmt.Errorf("There was a problem decoding the message: %v", err)}
+	}
+
+	return getReport(ctx, msg)
+}
+
+// serveProxy gets the report for an HTTP event and returns the response
+func serveProxy(ctx context.Context, getReport reportFunc, req proxyRequest) proxyResponse {
+	switch strings.ToUpper(req.Method) {
+	case "GET", "HEAD":
+	case "OPTIONS":
+		//	CORS preflight
+		return proxyResponse{StatusCode: http.StatusNoContent, Headers: apiHeaders(http.StatusNoContent)}
+	default:
+		return newProxyResponse(http.StatusMethodNotAllowed, errorBody(fmt.Errorf("Method %s isn't allowed", req.Method)))
+	}
+
+	msg, kind := proxyMessage(req)
+
+	return newProxyResponse(respond(ctx, getReport, msg, kind))
+}
+
+// proxyMessage builds the message for an HTTP event.  The zipcode comes from
+// the 'zip' (or 'zipcode') path parameter, then the query string, then the last
+// part of the path (so /v1/pollen ends up with 'pollen', which respond turns
+// down).  Paths ending in /history (or /series) are history (or series)
+// requests, and paths like /forecast/{kind}/{zip} (or a 'kind' path parameter)
+// get a single kind of forecast
+func proxyMessage(req proxyRequest) (Message, string) {
+	parts := strings.Split(strings.Trim(req.Path, "/"), "/")
+
+	mode := ""
+	if len(parts) > 0 && (parts[len(parts)-1] == modeHistory || parts[len(parts)-1] == modeSeries) {
+		mode = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	zipcode := firstNonEmpty(req.PathParameters["zip"], req.PathParameters["zipcode"], req.Query.Get("zip"), req.Query.Get("zipcode"))
+	if zipcode == "" && len(parts) > 0 {
+		zipcode = parts[len(parts)-1]
+	}
+
+	msg := queryMessage(req.Query, zipcode)
+	if mode != "" {
+		msg.Mode = mode
+	}
+
+	kind := req.PathParameters["kind"]
+	if kind == "" && len(parts) >= 3 && parts[len(parts)-3] == "forecast" {
+		kind = parts[len(parts)-2]
+	}
+	if kind != "" {
+		msg.Kinds = []string{kind}
+	}
+
+	return msg, kind
+}
+
+// newProxyResponse returns the proxy response with the status code and JSON body
+func newProxyResponse(status int, body interface{}) proxyResponse {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		status = http.StatusInternalServerError
+		encoded, _ = json.Marshal(errorResponse{Error: fmt.Sprintf("There was a problem encoding the response: %v", err)})
+	}
+
+	return proxyResponse{StatusCode: status, Headers: apiHeaders(status), Body: string(encoded)}
+}
+
+// singleValues converts single value query string parameters to url.Values
+func singleValues(params map[string]string) url.Values {
+	retval := url.Values{}
+	for name, value := range params {
+		retval.Set(name, value)
+	}
+
+	return retval
+}
+
+// unescape decodes a query string name or value (or returns it as-is if it
+// can't be decoded)
+func unescape(value string) string {
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		return unescaped
+	}
+
+	return value
+}
+
+// firstNonEmpty returns the first value that isn't blank
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/danesparza/pollen/data"
+)
+
+// loadEvent loads the Lambda event fixture from testdata/events
+func loadEvent(t *testing.T, name string) json.RawMessage {
+	t.Helper()
+
+	event, err := ioutil.ReadFile(filepath.Join("testdata", "events", name))
+	if err != nil {
+		t.Fatalf("Unable to load event %s: %v", name, err)
+	}
+
+	return event
+}
+
+// recordingReport returns a reportFunc that records the message it was called
+// with.  Zipcode 00000 fails as if none of the services came through
+func recordingReport(got *Message) reportFunc {
+	return func(ctx context.Context, msg Message) (data.PollenReport, error) {
+		*got = msg
+		if msg.Zipcode == "00000" {
+			return data.PollenReport{}, &data.MultiProviderError{Zipcode: msg.Zipcode, Errors: []*data.ProviderError{
+				{Service: "Nasacort", Reason: data.FailureHTTPStatus, StatusCode: 503},
+			}}
+		}
+
+		return data.PollenReport{Zipcode: msg.Zipcode, Data: []float64{1, 2}}, nil
+	}
+}
+
+func TestHandleEvent_APIGatewayV1_ReturnsProxyResponse(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := loadEvent(t, "apigateway_v1.json")
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	proxy, ok := response.(httpEventResponse)
+	if !ok {
+		t.Fatalf("Expected an httpEventResponse, but got %T", response)
+	}
+
+	if proxy.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, but got %d: %s", proxy.StatusCode, proxy.Body)
+	}
+
+	expected := Message{Zipcode: "30019", Strategy: "consensus", Kinds: []string{"pollen", "asthma"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected message %+v, but got %+v", expected, got)
+	}
+
+	if proxy.Headers["Access-Control-Allow-Origin"] != "*" || proxy.Headers["Cache-Control"] != "public, max-age=1800" {
+		t.Errorf("Unexpected headers: %+v", proxy.Headers)
+	}
+
+	report := data.PollenReport{}
+	if err := json.Unmarshal([]byte(proxy.Body), &report); err != nil || report.Zipcode != "30019" {
+		t.Errorf("Expected the report in the body, but got %s", proxy.Body)
+	}
+}
+
+func TestHandleEvent_APIGatewayV2_ReturnsHTTPResponse(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := loadEvent(t, "apigateway_v2.json")
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	proxy, ok := response.(httpEventResponse)
+	if !ok {
+		t.Fatalf("Expected an httpEventResponse, but got %T", response)
+	}
+
+	if proxy.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, but got %d: %s", proxy.StatusCode, proxy.Body)
+	}
+
+	expected := Message{Zipcode: "30019", Mode: modeHistory, Start: "2019-03-19", End: "2019-04-17"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected message %+v, but got %+v", expected, got)
+	}
+}
+
+func TestHandleEvent_ProxyErrors_ReturnJSONErrorBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  string
+		status int
+	}{
+		{"services failed", `{"httpMethod": "GET", "path": "/pollen/00000", "requestContext": {}}`, http.StatusBadGateway},
+		{"zip in query", `{"version": "2.0", "rawPath": "/pollen", "rawQueryString": "zip=00000", "requestContext": {"http": {"method": "GET"}}}`, http.StatusBadGateway},
+		{"missing zip", `{"httpMethod": "GET", "path": "/", "requestContext": {}}`, http.StatusBadRequest},
+		{"no zip in path", `{"httpMethod": "GET", "path": "/v1/pollen", "requestContext": {}}`, http.StatusBadRequest},
+		{"wrong method", `{"version": "2.0", "rawPath": "/pollen/30019", "requestContext": {"http": {"method": "POST"}}}`, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//	Arrange
+			var got Message
+
+			//	Act
+			response, err := handleEvent(context.Background(), json.RawMessage(tt.event), recordingReport(&got))
+
+			//	Assert
+			if err != nil {
+				t.Fatalf("Expected errors in the proxy response, but got %v", err)
+			}
+
+			encoded, _ := json.Marshal(response)
+			proxy := httpEventResponse{}
+			if err := json.Unmarshal(encoded, &proxy); err != nil {
+				t.Fatalf("Unable to decode the response: %v", err)
+			}
+
+			if proxy.StatusCode != tt.status {
+				t.Errorf("Expected status %d, but got %d", tt.status, proxy.StatusCode)
+			}
+
+			if proxy.Headers["Cache-Control"] != "no-store" || proxy.Headers["Access-Control-Allow-Origin"] == "" {
+				t.Errorf("Unexpected headers for an error: %+v", proxy.Headers)
+			}
+
+			body := errorResponse{}
+			if err := json.Unmarshal([]byte(proxy.Body), &body); err != nil || body.Error == "" {
+				t.Errorf("Expected a JSON error body, but got %s", proxy.Body)
+			}
+		})
+	}
+}
+
+func TestHandleEvent_Preflight_ReturnsNoContent(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := json.RawMessage(`{"httpMethod": "OPTIONS", "path": "/pollen/30019", "requestContext": {}}`)
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	proxy, ok := response.(httpEventResponse)
+	if err != nil || !ok {
+		t.Fatalf("Expected a proxy response, but got %T (%v)", response, err)
+	}
+
+	if proxy.StatusCode != http.StatusNoContent || proxy.Headers["Access-Control-Allow-Methods"] == "" {
+		t.Errorf("Unexpected preflight response: %+v", proxy)
+	}
+
+	if got.Zipcode != "" {
+		t.Errorf("Expected the preflight not to get a report, but got %+v", got)
+	}
+}
+
+func TestHandleEvent_Message_ReturnsReport(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := json.RawMessage(`{"zipcode": "30019", "strategy": "first"}`)
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if report, ok := response.(data.PollenReport); !ok || report.Zipcode != "30019" {
+		t.Errorf("Expected the report, but got %+v", response)
+	}
+
+	if got.Strategy != "first" {
+		t.Errorf("Expected the message to be passed along, but got %+v", got)
+	}
+}
+
+func TestHandleEvent_FunctionURL_ReturnsHTTPResponse(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := loadEvent(t, "function_url.json")
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	proxy, ok := response.(httpEventResponse)
+	if !ok {
+		t.Fatalf("Expected an httpEventResponse, but got %T", response)
+	}
+
+	if proxy.StatusCode != http.StatusOK || proxy.Headers["Content-Type"] != "application/json; charset=utf-8" {
+		t.Errorf("Unexpected response: %+v", proxy)
+	}
+
+	expected := Message{Zipcode: "30019", Kinds: []string{"coldflu"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected message %+v, but got %+v", expected, got)
+	}
+}
+
+func TestHandleEvent_ALB_ReturnsTargetGroupResponse(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := loadEvent(t, "alb.json")
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	proxy, ok := response.(albTargetGroupResponse)
+	if !ok {
+		t.Fatalf("Expected an albTargetGroupResponse, but got %T", response)
+	}
+
+	if proxy.StatusCode != http.StatusOK || proxy.StatusDescription != "200 OK" {
+		t.Errorf("Unexpected status: %d %q", proxy.StatusCode, proxy.StatusDescription)
+	}
+
+	if proxy.Headers["Cache-Control"] == "" || proxy.MultiValueHeaders != nil {
+		t.Errorf("Expected single value headers, but got %+v / %+v", proxy.Headers, proxy.MultiValueHeaders)
+	}
+
+	expected := Message{Zipcode: "30019", Strategy: "first", Kinds: []string{"asthma"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected message %+v, but got %+v", expected, got)
+	}
+}
+
+func TestHandleEvent_ALBMultiValue_ReturnsMultiValueHeaders(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := loadEvent(t, "alb_multivalue.json")
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	proxy, ok := response.(albTargetGroupResponse)
+	if !ok {
+		t.Fatalf("Expected an albTargetGroupResponse, but got %T", response)
+	}
+
+	if proxy.Headers != nil || !reflect.DeepEqual(proxy.MultiValueHeaders["Cache-Control"], []string{"public, max-age=1800"}) {
+		t.Errorf("Expected multi value headers, but got %+v / %+v", proxy.Headers, proxy.MultiValueHeaders)
+	}
+
+	//	The load balancer doesn't decode the query string for us
+	expected := Message{Zipcode: "30019", Merge: "mean", Kinds: []string{"pollen", "asthma", "coldflu"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected message %+v, but got %+v", expected, got)
+	}
+}
+
+func TestHandleEvent_ALBServicesFailed_ReturnsErrorResponse(t *testing.T) {
+	//	Arrange
+	var got Message
+	event := json.RawMessage(`{"requestContext": {"elb": {}}, "httpMethod": "GET", "path": "/pollen/00000"}`)
+
+	//	Act
+	response, err := handleEvent(context.Background(), event, recordingReport(&got))
+
+	//	Assert
+	proxy, ok := response.(albTargetGroupResponse)
+	if err != nil || !ok {
+		t.Fatalf("Expected a target group response, but got %T (%v)", response, err)
+	}
+
+	if proxy.StatusCode != http.StatusBadGateway || proxy.StatusDescription != "502 Bad Gateway" {
+		t.Errorf("Unexpected status: %d %q", proxy.StatusCode, proxy.StatusDescription)
+	}
+
+	body := errorResponse{}
+	if err := json.Unmarshal([]byte(proxy.Body), &body); err != nil || len(body.Providers) != 1 {
+		t.Errorf("Expected a JSON error body with the providers, but got %s", proxy.Body)
+	}
+}
@@ -2,9 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/danesparza/pollen/data"
 )
@@ -16,30 +25,78 @@ var (
 	// CommitID is the git commitId for the app.  It's filled in as
 	// part of the automated build
 	CommitID string
+
+	//	reportCache caches pollen reports between invocations on a warm container
+	//	(or requests in server mode).  It's nil if caching is turned off
+	reportCache = newReportCache()
+
+	//	staleFallback serves the last good report for a zipcode when every
+	//	service fails.  It's nil if the fallback is turned off
+	staleFallback = newStaleFallback()
+
+	//	reportArchive keeps each served report (once per zipcode per day) for
+	//	trend analysis.  It's nil if archiving is turned off
+	reportArchive = newReportArchive()
+
+	//	reportCoalescer merges concurrent requests for the same zipcode, so they
+	//	share one set of service calls
+	reportCoalescer = &data.Coalescer{}
+
+	//	serviceResilience gives each service call a timeout and retries, and
+	//	keeps a circuit breaker for each service across requests
+	serviceResilience = newResilience()
+
+	//	activeProviders are the services to call, in order.  They're chosen (and
+	//	built, and the provider config is loaded) once, at startup -- the Lambda
+	//	handler and server won't start if that failed
+	activeProviders = newActiveProviders()
 )
 
 // Message is a custom struct event type to handle the Lambda input
 type Message struct {
-	Zipcode string `json:"zipcode"`
+	Zipcode  string   `json:"zipcode"`
+	Strategy string   `json:"strategy"` // How to combine the services: 'first' (the default), 'consensus' or 'hedged'
+	Merge    string   `json:"merge"`    // How the consensus strategy merges each day: 'median' (the default) or 'mean'
+	Kinds    []string `json:"kinds"`    // The kinds of forecasts to get: 'pollen', 'asthma' and/or 'coldflu' (optional -- defaults to just the pollen report)
+	Mode     string   `json:"mode"`     // 'forecast' (the default), 'history' to also get the observed pollen for past days, or 'series' to get just the archived daily index
+	Start    string   `json:"start"`    // The first date (YYYY-MM-DD) of history (or the series) to get (optional -- defaults to 30 days ago)
+	End      string   `json:"end"`      // The last date (YYYY-MM-DD) of history (or the series) to get (optional -- defaults to today)
+	Services []string `json:"services"` // The registered names of the providers to call, in order (optional -- defaults to the active providers)
 }
 
-// HandleRequest handles the AWS lambda request
-func HandleRequest(ctx context.Context, msg Message) (data.PollenReport, error) {
+// requestError is returned when there's a problem with the request itself
+// (rather than with the services)
+type requestError struct {
+	err error
+}
+
+// Error returns the error message for the request problem
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// Request modes
+const (
+	modeForecast = "forecast"
+	modeHistory  = "history"
+	modeSeries   = "series"
+)
+
+// HandleRequest handles the AWS lambda request.  The event can be a Message, or
+// an HTTP event (API Gateway proxy, Function URL or ALB target group) -- which
+// gets a response with the status code, headers and JSON body
+func HandleRequest(ctx context.Context, event json.RawMessage) (interface{}, error) {
 	xray.Configure(xray.Config{LogLevel: "trace"})
 	ctx, seg := xray.BeginSegment(ctx, "pollen-lambda-handler")
 
-	//	Set the services to call with
-	services := []data.PollenService{
-		data.NasacortService{},
-		data.PollencomService{},
+	//	Get the response
+	response, err := handleEvent(ctx, event, getReport)
+	if err != nil {
+		//	Let Lambda know none of the services came through
+		seg.Close(err)
+		return response, err
 	}
 
-	//	Call the helper method to get the report:
-	response := data.GetPollenReport(ctx, services, msg.Zipcode)
-
-	//	Set the service version information:
-	response.Version = fmt.Sprintf("%s.%s", BuildVersion, CommitID)
-
 	//	Close the segment
 	seg.Close(nil)
 
@@ -47,7 +104,571 @@ func HandleRequest(ctx context.Context, msg Message) (data.PollenReport, error)
 	return response, nil
 }
 
+// getReport gets the report requested in the message
+func getReport(ctx context.Context, msg Message) (data.PollenReport, error) {
+	if err := checkZipcode(msg.Zipcode); err != nil {
+		return data.PollenReport{}, err
+	}
+
+	//	Set the services to call with (the request can pick from the active ones)
+	providers := activeProviders
+	if providers.err != nil {
+		return data.PollenReport{}, providers.err
+	}
+	if len(msg.Services) > 0 {
+		picked, err := providers.pick(msg.Services)
+		if err != nil {
+			return data.PollenReport{}, &requestError{err}
+		}
+		providers = picked
+	}
+	services := providers.services
+
+	//	Figure out how to combine the services
+	strategy, err := data.NewStrategy(msg.Strategy, data.MergeMethod(msg.Merge))
+	if err != nil {
+		return data.PollenReport{}, &requestError{err}
+	}
+	switch configured := strategy.(type) {
+	case data.Hedged:
+		configured.Delay = hedgeDelay()
+		configured.Priorities = providers.priorities
+		strategy = configured
+	case data.Consensus:
+		configured.Timeout = consensusTimeout()
+		strategy = configured
+	}
+	strategy = reportCoalescer.Strategy(strategy)
+	if reportCache != nil {
+		strategy = reportCache.Strategy(strategy)
+	}
+	if reportArchive != nil {
+		strategy = reportArchive.Strategy(strategy)
+	}
+	if staleFallback != nil {
+		strategy = staleFallback.Strategy(strategy)
+	}
+
+	//	Make sure we know what mode we're in
+	mode := strings.ToLower(msg.Mode)
+	if mode != "" && mode != modeForecast && mode != modeHistory && mode != modeSeries {
+		return data.PollenReport{}, &requestError{fmt.Errorf("Unknown mode '%s'", msg.Mode)}
+	}
+
+	start, err := parseDate(msg.Start)
+	if err != nil {
+		return data.PollenReport{}, &requestError{err}
+	}
+
+	end, err := parseDate(msg.End)
+	if err != nil {
+		return data.PollenReport{}, &requestError{err}
+	}
+
+	//	Check the history (or series) range before calling any services
+	switch mode {
+	case modeHistory:
+		if start, end, err = data.HistoryRange(start, end); err != nil {
+			return data.PollenReport{}, &requestError{err}
+		}
+	case modeSeries:
+		if start, end, err = data.SeriesRange(start, end); err != nil {
+			return data.PollenReport{}, &requestError{err}
+		}
+	}
+
+	//	The series comes straight from the archive -- the services aren't called
+	if mode == modeSeries {
+		if reportArchive == nil {
+			return data.PollenReport{}, &requestError{fmt.Errorf("The archive is turned off, so there's no series")}
+		}
+
+		series, err := data.GetIndexSeries(ctx, reportArchive.Store, msg.Zipcode, start, end)
+		if err != nil {
+			return data.PollenReport{}, err
+		}
+
+		return data.PollenReport{Zipcode: series.Zipcode, Series: &series, Version: version()}, nil
+	}
+
+	//	Figure out which kinds of forecasts we need
+	wantPollen := len(msg.Kinds) == 0
+	otherKinds := []data.ForecastKind{}
+	for _, name := range msg.Kinds {
+		kind, err := data.ParseForecastKind(name)
+		if err != nil {
+			return data.PollenReport{}, &requestError{err}
+		}
+
+		if kind == data.KindPollen {
+			wantPollen = true
+			continue
+		}
+		otherKinds = append(otherKinds, kind)
+	}
+
+	response := data.PollenReport{Zipcode: msg.Zipcode}
+
+	//	Every call to the services gets timeouts, retries and a circuit breaker
+	resilient := serviceResilience.Services(services)
+
+	//	Call the helper method to get the report:
+	if wantPollen {
+		response, err = strategy.Aggregate(ctx, resilient, msg.Zipcode)
+		if err != nil {
+			return response, err
+		}
+	}
+
+	//	If specific kinds were requested, include each of them in the same day by day shape
+	if len(msg.Kinds) > 0 {
+		forecasts, err := data.GetForecasts(ctx, resilient, otherKinds, msg.Zipcode)
+		if err != nil && !wantPollen {
+			return data.PollenReport{}, err
+		}
+
+		if wantPollen {
+			forecasts[data.KindPollen] = data.ForecastFromReport(response)
+		}
+
+		//	If we didn't get the pollen report, use the location from the other forecasts
+		for _, kind := range otherKinds {
+			if response.Location == "" {
+				response.Location = forecasts[kind].Location
+			}
+		}
+
+		response.Forecasts = forecasts
+	}
+
+	//	If history was requested, include it so it can be compared to today
+	if mode == modeHistory {
+		history, err := data.GetPollenHistory(ctx, resilient, msg.Zipcode, start, end)
+		if err != nil {
+			return data.PollenReport{}, err
+		}
+		response.History = &history
+	}
+
+	//	Set the service version information:
+	response.Version = version()
+
+	return response, nil
+}
+
+// providerSet is the services for a set of providers, in order
+type providerSet struct {
+	infos      []data.ProviderInfo  // The registered provider for each service
+	services   []data.PollenService // The service for each provider
+	priorities map[string]int       // The hedged strategy's priority for each service that has one, by service name
+	err        error                // The problem choosing the providers (if there was one)
+}
+
+// selectProviders builds the services for the named providers, in order (every
+// available provider if no names are passed).  Each service's priority for the
+// hedged strategy comes from POLLEN_PRIORITIES (like
+// 'pollencom=1,nasacort=1,google=2'), or else the provider's registered priority
+func selectProviders(names []string) (providerSet, error) {
+	selected, err := data.DefaultRegistry.SelectProviders(names, providerSettings)
+	if err != nil {
+		return providerSet{}, err
+	}
+
+	configured := providerPriorities()
+	set := providerSet{priorities: map[string]int{}}
+	for _, provider := range selected {
+		set.infos = append(set.infos, provider.Info)
+		set.services = append(set.services, provider.Service)
+
+		priority, ok := configured[strings.ToLower(provider.Info.Name)]
+		if !ok && provider.Info.Priority != 0 {
+			priority, ok = provider.Info.Priority, true
+		}
+		if ok {
+			set.priorities[data.ServiceName(provider.Service)] = priority
+		}
+	}
+
+	return set, nil
+}
+
+// pick returns the named providers from the set, in order (skipping any
+// repeats).  It's an error to name a provider that isn't in the set
+func (p providerSet) pick(names []string) (providerSet, error) {
+	picked := providerSet{priorities: map[string]int{}}
+	seen := map[string]bool{}
+	for _, name := range names {
+		index := -1
+		for i, active := range p.infos {
+			if strings.EqualFold(active.Name, strings.TrimSpace(name)) {
+				index = i
+			}
+		}
+
+		if index < 0 {
+			if _, registered := data.DefaultRegistry.Info(name); registered {
+				return providerSet{}, fmt.Errorf("The %s provider isn't active -- the active providers are %s", name, strings.Join(p.names(), ", "))
+			}
+			return providerSet{}, fmt.Errorf("Unknown provider '%s' -- the active providers are %s", name, strings.Join(p.names(), ", "))
+		}
+
+		info := p.infos[index]
+		if seen[info.Name] {
+			continue
+		}
+		seen[info.Name] = true
+
+		service := p.services[index]
+		picked.infos = append(picked.infos, info)
+		picked.services = append(picked.services, service)
+		if priority, ok := p.priorities[data.ServiceName(service)]; ok {
+			picked.priorities[data.ServiceName(service)] = priority
+		}
+	}
+
+	return picked, nil
+}
+
+// names returns the registered name of each provider
+func (p providerSet) names() []string {
+	names := []string{}
+	for _, info := range p.infos {
+		names = append(names, info.Name)
+	}
+
+	return names
+}
+
+// selected returns each provider with its service
+func (p providerSet) selected() []data.SelectedProvider {
+	selected := []data.SelectedProvider{}
+	for index, info := range p.infos {
+		selected = append(selected, data.SelectedProvider{Info: info, Service: p.services[index]})
+	}
+
+	return selected
+}
+
+// providerPriorities returns the priorities in POLLEN_PRIORITIES (like
+// 'pollencom=1,google=2'), by lowercase provider name.  Entries that can't be
+// read are left out
+func providerPriorities() map[string]int {
+	priorities := map[string]int{}
+	for _, item := range splitList(os.Getenv("POLLEN_PRIORITIES")) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			log.Printf("[WARN] Invalid POLLEN_PRIORITIES entry '%s' -- it should be like 'pollencom=1'", item)
+			continue
+		}
+
+		priority, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			log.Printf("[WARN] Invalid POLLEN_PRIORITIES entry '%s': %v", item, err)
+			continue
+		}
+
+		priorities[strings.ToLower(strings.TrimSpace(parts[0]))] = priority
+	}
+
+	return priorities
+}
+
+// newActiveProviders registers the services in the POLLEN_PROVIDER_CONFIG file
+// (if it's set) and builds the services for the providers to call, in order.
+// They come from POLLEN_PROVIDERS (like 'pollencom,nasacort'), or the config
+// file's 'active' list -- if neither is set, every provider that's available is
+// called.  A bad config is kept as the set's error, so the entrypoints can
+// refuse to start rather than quietly leaving providers out
+func newActiveProviders() providerSet {
+	active := []string{}
+
+	if path := os.Getenv("POLLEN_PROVIDER_CONFIG"); path != "" {
+		config, err := data.ReadProviderConfig(path)
+		if err != nil {
+			return providerSet{err: err}
+		}
+
+		services, err := config.Services()
+		if err != nil {
+			return providerSet{err: fmt.Errorf("The provider config %s is invalid: %v", path, err)}
+		}
+
+		for _, service := range services {
+			service := service
+			err := data.DefaultRegistry.Register(service.Info(), func(data.ProviderSettings) (data.PollenService, error) {
+				return service, nil
+			})
+			if err != nil {
+				return providerSet{err: err}
+			}
+		}
+
+		active = config.Active
+	}
+
+	if providers := splitList(os.Getenv("POLLEN_PROVIDERS")); len(providers) > 0 {
+		active = providers
+	}
+
+	set, err := selectProviders(active)
+	if err != nil {
+		return providerSet{err: err}
+	}
+
+	return set
+}
+
+// providerSettings returns the settings for the named provider.  Its API key
+// comes from POLLEN_{NAME}_API_KEY (with anything but letters and numbers in
+// the name as underscores), like POLLEN_GOOGLE_API_KEY.  Its country comes
+// from POLLEN_{NAME}_COUNTRY, and its coordinates file from
+// POLLEN_{NAME}_COORDINATES
+func providerSettings(name string) data.ProviderSettings {
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+
+	return data.ProviderSettings{
+		APIKey:      strings.TrimSpace(os.Getenv("POLLEN_" + key + "_API_KEY")),
+		Country:     strings.TrimSpace(os.Getenv("POLLEN_" + key + "_COUNTRY")),
+		Coordinates: strings.TrimSpace(os.Getenv("POLLEN_" + key + "_COORDINATES")),
+	}
+}
+
+// splitList splits a comma separated list, leaving out blanks
+func splitList(list string) []string {
+	retval := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			retval = append(retval, item)
+		}
+	}
+
+	return retval
+}
+
+// newReportCache returns the report cache, configured by POLLEN_CACHE_TTL (like
+// '30m' -- '0' turns caching off) and POLLEN_CACHE_STORE ('memory' or 'dynamodb').
+// The memory store is sized by POLLEN_CACHE_SIZE, and the DynamoDB store uses
+// the POLLEN_DYNAMODB_TABLE table (and POLLEN_DYNAMODB_ENDPOINT, if it's set)
+func newReportCache() *data.Cache {
+	ttl, err := time.ParseDuration(envOrDefault("POLLEN_CACHE_TTL", data.DefaultCacheTTL.String()))
+	if err != nil {
+		log.Printf("[WARN] Invalid POLLEN_CACHE_TTL -- using %s: %v", data.DefaultCacheTTL, err)
+		ttl = data.DefaultCacheTTL
+	}
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	switch store := envOrDefault("POLLEN_CACHE_STORE", "memory"); store {
+	case "dynamodb":
+		config := &aws.Config{}
+		if endpoint := os.Getenv("POLLEN_DYNAMODB_ENDPOINT"); endpoint != "" {
+			config.Endpoint = aws.String(endpoint)
+		}
+
+		dynamo, err := data.NewDynamoDBStore(envOrDefault("POLLEN_DYNAMODB_TABLE", data.DefaultDynamoDBTable), config)
+		if err != nil {
+			log.Printf("[WARN] Caching in memory instead of DynamoDB: %v", err)
+			break
+		}
+
+		return &data.Cache{Store: dynamo, TTL: ttl}
+
+	case "memory":
+	default:
+		log.Printf("[WARN] Unknown POLLEN_CACHE_STORE '%s' -- caching in memory", store)
+	}
+
+	size, err := strconv.Atoi(envOrDefault("POLLEN_CACHE_SIZE", strconv.Itoa(data.DefaultCacheSize)))
+	if err != nil {
+		log.Printf("[WARN] Invalid POLLEN_CACHE_SIZE -- using %d: %v", data.DefaultCacheSize, err)
+		size = data.DefaultCacheSize
+	}
+
+	return &data.Cache{Store: &data.MemoryStore{Size: size}, TTL: ttl}
+}
+
+// newStaleFallback returns the stale fallback, configured by POLLEN_STALE_STORE
+// ('memory', 'file' or 'none' to turn it off).  The file store keeps reports in
+// POLLEN_STALE_DIR
+func newStaleFallback() *data.StaleFallback {
+	switch store := envOrDefault("POLLEN_STALE_STORE", "memory"); store {
+	case "none":
+		return nil
+	case "file":
+		return &data.StaleFallback{Store: data.FileStore{Dir: os.Getenv("POLLEN_STALE_DIR")}}
+	case "memory":
+	default:
+		log.Printf("[WARN] Unknown POLLEN_STALE_STORE '%s' -- keeping stale reports in memory", store)
+	}
+
+	return &data.StaleFallback{Store: &data.MemoryStore{}}
+}
+
+// hedgeDelay returns how long the hedged strategy waits on a tier, configured
+// by POLLEN_HEDGE_DELAY (like '1s')
+func hedgeDelay() time.Duration {
+	delay, err := time.ParseDuration(envOrDefault("POLLEN_HEDGE_DELAY", data.DefaultHedgeDelay.String()))
+	if err != nil {
+		log.Printf("[WARN] Invalid POLLEN_HEDGE_DELAY -- using %s: %v", data.DefaultHedgeDelay, err)
+		return data.DefaultHedgeDelay
+	}
+
+	return delay
+}
+
+// consensusTimeout returns how long the consensus strategy waits on the
+// services, configured by POLLEN_CONSENSUS_TIMEOUT (like '5s')
+func consensusTimeout() time.Duration {
+	timeout, err := time.ParseDuration(envOrDefault("POLLEN_CONSENSUS_TIMEOUT", data.DefaultConsensusTimeout.String()))
+	if err != nil || timeout <= 0 {
+		log.Printf("[WARN] Invalid POLLEN_CONSENSUS_TIMEOUT -- using %s: %v", data.DefaultConsensusTimeout, err)
+		return data.DefaultConsensusTimeout
+	}
+
+	return timeout
+}
+
+// newResilience returns the service resilience settings, configured by
+// POLLEN_ATTEMPT_TIMEOUT (like '4s'), POLLEN_RETRIES ('-1' turns retries off),
+// POLLEN_BREAKER_THRESHOLD and POLLEN_BREAKER_COOLDOWN (like '30s')
+func newResilience() *data.Resilience {
+	resilience := &data.Resilience{}
+
+	if value := os.Getenv("POLLEN_ATTEMPT_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("[WARN] Invalid POLLEN_ATTEMPT_TIMEOUT -- using %s: %v", data.DefaultAttemptTimeout, err)
+		} else {
+			resilience.AttemptTimeout = timeout
+		}
+	}
+
+	if value := os.Getenv("POLLEN_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil {
+			log.Printf("[WARN] Invalid POLLEN_RETRIES -- using %d: %v", data.DefaultRetries, err)
+		} else {
+			resilience.Retries = retries
+		}
+	}
+
+	if value := os.Getenv("POLLEN_BREAKER_THRESHOLD"); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			log.Printf("[WARN] Invalid POLLEN_BREAKER_THRESHOLD -- using %d: %v", data.DefaultBreakerThreshold, err)
+		} else {
+			resilience.BreakerThreshold = threshold
+		}
+	}
+
+	if value := os.Getenv("POLLEN_BREAKER_COOLDOWN"); value != "" {
+		cooldown, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("[WARN] Invalid POLLEN_BREAKER_COOLDOWN -- using %s: %v", data.DefaultBreakerCooldown, err)
+		} else {
+			resilience.BreakerCooldown = cooldown
+		}
+	}
+
+	return resilience
+}
+
+// newReportArchive returns the report archive, configured by POLLEN_ARCHIVE
+// ('none' -- the default -- 'local' or 's3').  The local store keeps reports in
+// POLLEN_ARCHIVE_DIR, and the S3 store uses the POLLEN_ARCHIVE_BUCKET bucket
+// (under POLLEN_ARCHIVE_PREFIX, and with POLLEN_S3_ENDPOINT if it's set)
+func newReportArchive() *data.Archive {
+	switch store := envOrDefault("POLLEN_ARCHIVE", "none"); store {
+	case "local":
+		return &data.Archive{Store: data.LocalReportStore{Dir: envOrDefault("POLLEN_ARCHIVE_DIR", "pollen-archive")}}
+
+	case "s3":
+		config := &aws.Config{}
+		if endpoint := os.Getenv("POLLEN_S3_ENDPOINT"); endpoint != "" {
+			config.Endpoint = aws.String(endpoint)
+		}
+
+		s3, err := data.NewS3ReportStore(os.Getenv("POLLEN_ARCHIVE_BUCKET"), os.Getenv("POLLEN_ARCHIVE_PREFIX"), config)
+		if err != nil {
+			log.Printf("[WARN] Not archiving reports: %v", err)
+			return nil
+		}
+
+		return &data.Archive{Store: s3}
+
+	case "none":
+	default:
+		log.Printf("[WARN] Unknown POLLEN_ARCHIVE '%s' -- not archiving reports", store)
+	}
+
+	return nil
+}
+
+// parseDate parses a YYYY-MM-DD date.  A blank date is the zero time
+func parseDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(data.DateFormat, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid date '%s' -- dates should be formatted as YYYY-MM-DD", date)
+	}
+
+	return parsed, nil
+}
+
+// version returns the service version information
+func version() string {
+	return fmt.Sprintf("%s.%s", BuildVersion, CommitID)
+}
+
 func main() {
-	//	Immediately forward to Lambda
+	//	If we got a command, run the command line client
+	if len(os.Args) > 1 && cliCommands[os.Args[1]] {
+		//	dry-run checks a provider config on its own, so it works even if the active providers don't
+		if activeProviders.err != nil && os.Args[1] != "dry-run" {
+			fmt.Fprintf(os.Stderr, "pollen: %v\n", activeProviders.err)
+			os.Exit(exitFailure)
+		}
+
+		client := cli{getReport: getReport, active: activeProviders.selected(), stdout: os.Stdout, stderr: os.Stderr}
+		os.Exit(client.run(os.Args[1:]))
+	}
+
+	serverMode := flag.Bool("server", os.Getenv("POLLEN_MODE") == "server", "Run as a standalone HTTP server instead of a Lambda handler (or set POLLEN_MODE=server)")
+	addr := flag.String("addr", envOrDefault("POLLEN_ADDR", ":3000"), "The address to serve on in server mode (or set POLLEN_ADDR)")
+	timeout := flag.Duration("timeout", 10*time.Second, "How long each request can take in server mode")
+	flag.Parse()
+
+	//	A bad provider config stops the handler (or server) before it takes any requests
+	if activeProviders.err != nil {
+		log.Fatalf("[ERROR] %v", activeProviders.err)
+	}
+
+	if *serverMode {
+		if err := runServer(*addr, *timeout, 30*time.Second); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	//	Otherwise, immediately forward to Lambda
 	lambda.Start(HandleRequest)
 }
+
+// envOrDefault returns the value of the environment variable, or the default if it's not set
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGetReport_UnknownService_ReturnsRequestError(t *testing.T) {
+	//	Arrange
+	msg := Message{Zipcode: "30019", Services: []string{"pollencom", "weather.com"}}
+
+	//	Act
+	_, err := getReport(context.Background(), msg)
+
+	//	Assert
+	if _, ok := err.(*requestError); !ok || !strings.Contains(err.Error(), "Unknown provider 'weather.com'") {
+		t.Errorf("Expected a request error for the unknown provider, but got %T (%v)", err, err)
+	}
+}
+
+func TestGetReport_ReversedHistory_ReturnsRequestError(t *testing.T) {
+	//	Arrange
+	msg := Message{Zipcode: "30019", Mode: modeHistory, Start: "2019-04-18", End: "2019-03-19"}
+
+	//	Act
+	_, err := getReport(context.Background(), msg)
+
+	//	Assert
+	if _, ok := err.(*requestError); !ok {
+		t.Errorf("Expected a request error for the reversed history range, but got %T (%v)", err, err)
+	}
+}
+
+func TestProviderSettings_ReadsAPIKeyFromEnvironment(t *testing.T) {
+	//	Arrange
+	os.Setenv("POLLEN_REGIONAL_POLLEN_API_KEY", " regional-key ")
+	defer os.Unsetenv("POLLEN_REGIONAL_POLLEN_API_KEY")
+
+	//	Act
+	settings := providerSettings("Regional Pollen")
+
+	//	Assert
+	if settings.APIKey != "regional-key" {
+		t.Errorf("Expected the key from POLLEN_REGIONAL_POLLEN_API_KEY, but got '%s'", settings.APIKey)
+	}
+}
+
+func TestSelectProviders_ReadsPrioritiesFromEnvironment(t *testing.T) {
+	//	Arrange
+	os.Setenv("POLLEN_PRIORITIES", "Nasacort=2, pollencom=1, bogus")
+	defer os.Unsetenv("POLLEN_PRIORITIES")
+
+	//	Act
+	set, err := selectProviders([]string{"nasacort", "pollencom"})
+
+	//	Assert
+	if err != nil || len(set.services) != 2 {
+		t.Fatalf("Expected both services, but got %d (%v)", len(set.services), err)
+	}
+
+	if set.priorities["Nasacort"] != 2 || set.priorities["Pollen.com"] != 1 || len(set.priorities) != 2 {
+		t.Errorf("Expected the priorities from POLLEN_PRIORITIES, but got %v", set.priorities)
+	}
+}
+
+func TestGetReport_InactiveProvider_ReturnsRequestError(t *testing.T) {
+	//	Arrange
+	msg := Message{Zipcode: "30019", Services: []string{"google"}}
+
+	//	Act
+	_, err := getReport(context.Background(), msg)
+
+	//	Assert
+	if _, ok := err.(*requestError); !ok || !strings.Contains(err.Error(), "isn't active") {
+		t.Errorf("Expected a request error for the inactive provider, but got %T (%v)", err, err)
+	}
+}
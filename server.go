@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/danesparza/pollen/data"
+)
+
+// cacheMaxAge is how long (in seconds) clients can cache a successful report
+const cacheMaxAge = 1800
+
+// reportFunc gets the report requested in a message
+type reportFunc func(ctx context.Context, msg Message) (data.PollenReport, error)
+
+// errorResponse is the JSON body returned for errors
+type errorResponse struct {
+	Error     string                `json:"error"`               // What went wrong
+	Providers []*data.ProviderError `json:"providers,omitempty"` // The failure for each service (if the services failed)
+}
+
+// healthResponse is the JSON body returned by the health endpoint
+type healthResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// runServer serves the API on the address until the process is interrupted,
+// then shuts down gracefully (waiting up to shutdownTimeout for requests to finish)
+func runServer(addr string, timeout, shutdownTimeout time.Duration) error {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: xray.Handler(xray.NewFixedSegmentNamer("pollen-server"), newRouter(getReport, timeout)),
+	}
+
+	//	Listen for the signal to shut down
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("[INFO] Serving the pollen API on %s", addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		log.Printf("[INFO] Got %s -- shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+// newRouter returns the handler for the API endpoints:
+//
+//	GET /v1/health                    - service health and version
+//	GET /v1/pollen/{zip}              - the pollen report (accepts strategy, merge, kinds and services query parameters)
+//	GET /v1/pollen/{zip}/history      - the pollen report with history (accepts start and end query parameters)
+//	GET /v1/pollen/{zip}/series       - the archived daily index (accepts start and end query parameters)
+//	GET /v1/forecast/{kind}/{zip}     - a single kind of forecast (pollen, asthma or coldflu)
+//
+// Each request gets at most timeout to finish
+func newRouter(getReport reportFunc, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			rw.Header().Set("Allow", "GET, HEAD")
+			writeError(rw, http.StatusMethodNotAllowed, fmt.Errorf("Method %s isn't allowed", req.Method))
+			return
+		}
+
+		parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+		switch {
+		case len(parts) == 2 && parts[0] == "v1" && parts[1] == "health":
+			writeJSON(rw, http.StatusOK, healthResponse{Status: "ok", Version: version()})
+
+		case len(parts) == 3 && parts[0] == "v1" && parts[1] == "pollen":
+			serveReport(rw, req, getReport, timeout, queryMessage(req.URL.Query(), parts[2]), "")
+
+		case len(parts) == 4 && parts[0] == "v1" && parts[1] == "pollen" && (parts[3] == modeHistory || parts[3] == modeSeries):
+			msg := queryMessage(req.URL.Query(), parts[2])
+			msg.Mode = parts[3]
+			serveReport(rw, req, getReport, timeout, msg, "")
+
+		case len(parts) == 4 && parts[0] == "v1" && parts[1] == "forecast":
+			msg := queryMessage(req.URL.Query(), parts[3])
+			msg.Kinds = []string{parts[2]}
+			serveReport(rw, req, getReport, timeout, msg, parts[2])
+
+		default:
+			writeError(rw, http.StatusNotFound, fmt.Errorf("%s wasn't found", req.URL.Path))
+		}
+	})
+}
+
+// queryMessage builds the message for a request from its query string
+func queryMessage(query url.Values, zipcode string) Message {
+	msg := Message{
+		Zipcode:  zipcode,
+		Strategy: query.Get("strategy"),
+		Merge:    query.Get("merge"),
+		Mode:     query.Get("mode"),
+		Start:    query.Get("start"),
+		End:      query.Get("end"),
+	}
+
+	for _, kinds := range query["kinds"] {
+		msg.Kinds = append(msg.Kinds, splitList(kinds)...)
+	}
+
+	for _, services := range query["services"] {
+		msg.Services = append(msg.Services, splitList(services)...)
+	}
+
+	return msg
+}
+
+// serveReport gets the report for the message and writes it (or the error).  If
+// a kind is passed, just that kind of forecast is written
+func serveReport(rw http.ResponseWriter, req *http.Request, getReport reportFunc, timeout time.Duration, msg Message, kind string) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	status, body := respond(ctx, getReport, msg, kind)
+
+	for name, value := range apiHeaders(status) {
+		rw.Header().Set(name, value)
+	}
+
+	writeJSON(rw, status, body)
+}
+
+// respond gets the report for the message and returns the HTTP status code and
+// the value for the JSON body (the report, or the error).  If a kind is passed,
+// just that kind of forecast is returned
+func respond(ctx context.Context, getReport reportFunc, msg Message, kind string) (int, interface{}) {
+	if err := checkZipcode(msg.Zipcode); err != nil {
+		return errorStatus(err), errorBody(err)
+	}
+
+	report, err := getReport(ctx, msg)
+	if err != nil {
+		return errorStatus(err), errorBody(err)
+	}
+
+	if kind != "" {
+		parsed, _ := data.ParseForecastKind(kind)
+		return http.StatusOK, report.Forecasts[parsed]
+	}
+
+	return http.StatusOK, report
+}
+
+// checkZipcode returns a request error if the zipcode isn't valid.  It's checked
+// before any services (or stores) are touched
+func checkZipcode(zipcode string) error {
+	if err := data.ValidateZipcode(zipcode); err != nil {
+		return &requestError{err}
+	}
+
+	return nil
+}
+
+// apiHeaders returns the CORS and caching headers for an API response with the
+// status code.  Successful responses can be cached for a while -- errors can't
+func apiHeaders(status int) map[string]string {
+	cacheControl := "no-store"
+	if status < 300 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", cacheMaxAge)
+	}
+
+	return map[string]string{
+		"Content-Type":                 "application/json; charset=utf-8",
+		"Cache-Control":                cacheControl,
+		"Access-Control-Allow-Origin":  envOrDefault("POLLEN_CORS_ORIGIN", "*"),
+		"Access-Control-Allow-Methods": "GET, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type",
+	}
+}
+
+// errorStatus returns the HTTP status code for an error from getReport
+func errorStatus(err error) int {
+	switch e := err.(type) {
+	case *requestError:
+		return http.StatusBadRequest
+
+	case *data.MultiProviderError:
+		//	If every service timed out, say so
+		for _, perr := range e.Errors {
+			if perr.Reason != data.FailureTimeout {
+				return http.StatusBadGateway
+			}
+		}
+		if len(e.Errors) > 0 {
+			return http.StatusGatewayTimeout
+		}
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+// errorBody returns the JSON body for an error
+func errorBody(err error) errorResponse {
+	response := errorResponse{Error: err.Error()}
+	if merr, ok := err.(*data.MultiProviderError); ok {
+		response.Providers = merr.Errors
+	}
+
+	return response
+}
+
+// writeError writes the JSON error response
+func writeError(rw http.ResponseWriter, status int, err error) {
+	writeJSON(rw, status, errorBody(err))
+}
+
+// writeJSON writes the value as the JSON response
+func writeJSON(rw http.ResponseWriter, status int, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+
+	if err := json.NewEncoder(rw).Encode(value); err != nil {
+		log.Printf("[ERROR] Unable to write the response: %v", err)
+	}
+}
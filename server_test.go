@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/danesparza/pollen/data"
+)
+
+func TestRouter_ServesEndpoints(t *testing.T) {
+	//	Arrange
+	var got Message
+	fake := func(ctx context.Context, msg Message) (data.PollenReport, error) {
+		got = msg
+		switch msg.Zipcode {
+		case "00000":
+			return data.PollenReport{}, &data.MultiProviderError{Zipcode: msg.Zipcode, Errors: []*data.ProviderError{
+				{Service: "Nasacort", Reason: data.FailureHTTPStatus, StatusCode: 503},
+				{Service: "Pollen.com", Reason: data.FailureTimeout},
+			}}
+		case "99999":
+			return data.PollenReport{}, &data.MultiProviderError{Zipcode: msg.Zipcode, Errors: []*data.ProviderError{
+				{Service: "Pollen.com", Reason: data.FailureTimeout},
+			}}
+		case "11111":
+			return data.PollenReport{}, &requestError{errors.New("bad request")}
+		case "22222":
+			return data.PollenReport{}, errors.New("boom")
+		}
+
+		return data.PollenReport{
+			Zipcode: msg.Zipcode,
+			Data:    []float64{1, 2},
+			Forecasts: map[data.ForecastKind]data.Forecast{
+				data.KindAsthma: {Kind: data.KindAsthma, Service: "Pollen.com"},
+			},
+		}, nil
+	}
+	router := newRouter(fake, time.Second)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		expected Message
+	}{
+		{"health", "GET", "/v1/health", http.StatusOK, Message{}},
+		{"report", "GET", "/v1/pollen/30019?strategy=consensus&merge=mean&kinds=pollen,asthma", http.StatusOK, Message{Zipcode: "30019", Strategy: "consensus", Merge: "mean", Kinds: []string{"pollen", "asthma"}}},
+		{"services", "GET", "/v1/pollen/30019?services=openmeteo,%20nasacort", http.StatusOK, Message{Zipcode: "30019", Services: []string{"openmeteo", "nasacort"}}},
+		{"history", "GET", "/v1/pollen/30019/history?start=2019-03-19", http.StatusOK, Message{Zipcode: "30019", Mode: modeHistory, Start: "2019-03-19"}},
+		{"series", "GET", "/v1/pollen/30019/series?start=2019-03-19&end=2019-04-18", http.StatusOK, Message{Zipcode: "30019", Mode: modeSeries, Start: "2019-03-19", End: "2019-04-18"}},
+		{"forecast", "GET", "/v1/forecast/asthma/30019", http.StatusOK, Message{Zipcode: "30019", Kinds: []string{"asthma"}}},
+		{"bad request", "GET", "/v1/pollen/11111", http.StatusBadRequest, Message{Zipcode: "11111"}},
+		{"invalid zipcode", "GET", "/v1/pollen/abcde/history", http.StatusBadRequest, Message{}},
+		{"services failed", "GET", "/v1/pollen/00000", http.StatusBadGateway, Message{Zipcode: "00000"}},
+		{"services timed out", "GET", "/v1/pollen/99999", http.StatusGatewayTimeout, Message{Zipcode: "99999"}},
+		{"unexpected error", "GET", "/v1/pollen/22222", http.StatusInternalServerError, Message{Zipcode: "22222"}},
+		{"unknown path", "GET", "/v1/weather/30019", http.StatusNotFound, Message{}},
+		{"wrong method", "POST", "/v1/pollen/30019", http.StatusMethodNotAllowed, Message{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Message{}
+			rw := httptest.NewRecorder()
+
+			//	Act
+			router.ServeHTTP(rw, httptest.NewRequest(tt.method, tt.path, nil))
+
+			//	Assert
+			if rw.Code != tt.status {
+				t.Errorf("Expected status %d, but got %d: %s", tt.status, rw.Code, rw.Body.String())
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected message %+v, but got %+v", tt.expected, got)
+			}
+
+			if contentType := rw.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+				t.Errorf("Expected a JSON response, but got '%s'", contentType)
+			}
+
+			if rw.Code >= 400 {
+				body := errorResponse{}
+				if err := json.Unmarshal(rw.Body.Bytes(), &body); err != nil || body.Error == "" {
+					t.Errorf("Expected a JSON error body, but got %s", rw.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_ServesSingleForecast(t *testing.T) {
+	//	Arrange
+	fake := func(ctx context.Context, msg Message) (data.PollenReport, error) {
+		return data.PollenReport{
+			Forecasts: map[data.ForecastKind]data.Forecast{
+				data.KindAsthma: {Kind: data.KindAsthma, Service: "Pollen.com"},
+			},
+		}, nil
+	}
+	rw := httptest.NewRecorder()
+
+	//	Act
+	newRouter(fake, time.Second).ServeHTTP(rw, httptest.NewRequest("GET", "/v1/forecast/Asthma/30019", nil))
+
+	//	Assert
+	forecast := data.Forecast{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &forecast); err != nil {
+		t.Fatalf("Unable to decode the response: %v", err)
+	}
+
+	if forecast.Kind != data.KindAsthma || forecast.Service != "Pollen.com" {
+		t.Errorf("Unexpected forecast: %+v", forecast)
+	}
+}